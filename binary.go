@@ -0,0 +1,82 @@
+package gofind
+
+import (
+	"io"
+	"os"
+	"unicode/utf8"
+)
+
+const (
+	// sniffLen is the length of the data looked at to decide if the content is binary
+	sniffLen = 8000
+
+	// maxInvalidUTF8Ratio is the ratio of bytes not part of valid UTF-8 sequences,
+	// above which the content is considered binary
+	maxInvalidUTF8Ratio = 0.3
+)
+
+// IsBinary reports whether data looks like binary content, by looking at its first block
+// The content is binary if the block has a NUL byte, or too many invalid UTF-8 sequences
+func IsBinary(data []byte) bool {
+	if len(data) > sniffLen {
+		data = data[:sniffLen]
+	}
+
+	invalid := 0
+	for i := 0; i < len(data); {
+		if data[i] == 0 {
+			return true
+		}
+
+		r, size := utf8.DecodeRune(data[i:])
+		if r == utf8.RuneError && size == 1 {
+			// A sequence cut at the end of the block is not invalid
+			if !utf8.FullRune(data[i:]) {
+				break
+			}
+			invalid++
+		}
+		i += size
+	}
+
+	return len(data) > 0 && float64(invalid)/float64(len(data)) > maxInvalidUTF8Ratio
+}
+
+// readFileHead reads the first block of a file, enough to sniff its content
+func readFileHead(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	block := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, block)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+
+	return block[:n], nil
+}
+
+// binaryPatterns returns the patterns to be applied on binary content, that is
+// the patterns with the Binary flag. The other patterns are disabled, keeping the
+// indices of the patterns unchanged. Returns nil if none of the patterns apply
+func binaryPatterns(patterns []SearchReplacePattern) []SearchReplacePattern {
+	enabled := false
+	binPatterns := make([]SearchReplacePattern, len(patterns))
+	copy(binPatterns, patterns)
+	for i := range binPatterns {
+		if binPatterns[i].Binary {
+			enabled = true
+		} else {
+			binPatterns[i].Occurrences = 0
+		}
+	}
+
+	if !enabled {
+		return nil
+	}
+
+	return binPatterns
+}
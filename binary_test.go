@@ -0,0 +1,74 @@
+package gofind
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsBinary(t *testing.T) {
+	assert.False(t, IsBinary([]byte("")))
+	assert.False(t, IsBinary([]byte("one two three\n")))
+	assert.False(t, IsBinary([]byte("Entscheidungsproblem – ‘decision problem’\n")))
+	assert.False(t, IsBinary([]byte("caf\xe9 au lait, na\xefve")))
+	assert.True(t, IsBinary([]byte("one\x00two")))
+	assert.True(t, IsBinary([]byte{0x89, 'P', 'N', 'G', 0xfe, 0xff, 0xc3, 0xa9, 0x81, 0x90}))
+
+	// A UTF-8 sequence cut at the end of the block is valid
+	data := make([]byte, sniffLen+1)
+	for i := range data {
+		data[i] = 'a'
+	}
+	copy(data[sniffLen-1:], "é")
+	assert.False(t, IsBinary(data))
+}
+
+func TestFileSearchReplaceWithOptions_Binary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	inFilePath := filepath.Join(dir, "f.bin")
+	err = ioutil.WriteFile(inFilePath, []byte("one\x00two"), 0644)
+	assert.NoError(t, err)
+
+	patterns := []SearchReplacePattern{
+		SearchReplacePattern{
+			SearchRegex:    makeRegex(t, "one"),
+			ReplacePattern: []byte("ONE"),
+			Occurrences:    -1,
+		},
+		SearchReplacePattern{
+			SearchRegex:    makeRegex(t, "two"),
+			ReplacePattern: []byte("TWO"),
+			Occurrences:    -1,
+		},
+	}
+
+	var result *FileResult
+	opts := FileOptions{DryRun: true, Report: func(r *FileResult) { result = r }}
+
+	// Skipped by default
+	updated, err := FileSearchReplaceWithOptions(inFilePath, inFilePath, patterns, nil, opts)
+	assert.NoError(t, err)
+	assert.False(t, updated)
+	assert.Equal(t, StatusBinary, result.Status)
+
+	// Patterns meant for binary files apply
+	patterns[1].Binary = true
+	_, err = FileSearchReplaceWithOptions(inFilePath, inFilePath, patterns, nil, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusUpdated, result.Status)
+	assert.Equal(t, 0, result.Patterns[0].Found)
+	assert.Equal(t, 1, result.Patterns[1].Replaced)
+
+	// All the patterns apply
+	opts.Binary = true
+	_, err = FileSearchReplaceWithOptions(inFilePath, inFilePath, patterns, nil, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Patterns[0].Replaced)
+	assert.Equal(t, 1, result.Patterns[1].Replaced)
+}
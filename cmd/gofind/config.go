@@ -0,0 +1,533 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/pelletier/go-toml"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// configSource records the files a configuration was read from, to locate its settings
+type configSource struct {
+	files []*configFile
+	// origins maps the path of a setting in the merged configuration, e.g. patterns[3]
+	// or inputDirectory, to the file and the path in the file it was read from
+	origins map[string]settingOrigin
+	// problems are the unknown keys found in the files, and the undefined variables
+	problems []diagnostic
+	// merged holds the absolute paths of the files merged
+	merged map[string]bool
+}
+
+// configFile is a configuration file, along with its YAML document
+type configFile struct {
+	path string
+	// root is nil if the file could not be parsed as YAML
+	root *yamlv3.Node
+}
+
+// settingOrigin is the file, and the path in the file, a setting was read from
+type settingOrigin struct {
+	file *configFile
+	path string
+}
+
+// loadConfig reads a configuration file, along with the files it extends and includes
+//
+// The configuration is merged in this order: the file named by 'extends', the files
+// listed in 'include', then the file itself. Lists (patterns, filters, globs, etc.)
+// are appended, the entries of maps (variables) and the other settings are overridden
+// by the files merged later, if they set them. The paths of the extended and included
+// files are relative to the file naming them. A file is merged once, where it is first reached
+func loadConfig(path string) (AppConfig, error) {
+	cfg, _, err := loadConfigSource(path)
+	return cfg, err
+}
+
+// loadConfigSource reads a configuration file like loadConfig, along with the origin of its settings
+func loadConfigSource(path string) (AppConfig, *configSource, error) {
+	var cfg AppConfig
+	src := &configSource{origins: make(map[string]settingOrigin), merged: make(map[string]bool)}
+	err := mergeConfigFile(&cfg, src, path, nil)
+	return cfg, src, err
+}
+
+// mergeConfigFile merges the configuration file into cfg
+// loading holds the files being loaded, that include path, to detect cycles
+func mergeConfigFile(cfg *AppConfig, src *configSource, path string, loading []string) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	for i := range loading {
+		if loading[i] == absPath {
+			err = fmt.Errorf("Config file cycle: %s", strings.Join(append(loading[i:], absPath), " -> "))
+			log.Print(err)
+			return err
+		}
+	}
+	// A file reached more than once, e.g. extended by two included files, is merged the first time only
+	if src.merged[absPath] {
+		return nil
+	}
+	src.merged[absPath] = true
+	loading = append(loading, absPath)
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Printf("Error reading config file %s. err=%v", path, err)
+		return err
+	}
+
+	var fileCfg AppConfig
+	var keys map[string]interface{}
+	ext := filepath.Ext(path)
+	if err = unmarshalConfig(data, ext, &fileCfg); err == nil {
+		err = unmarshalConfig(data, ext, &keys)
+		if err == nil {
+			file := parseConfigFile(path, data)
+			src.files = append(src.files, file)
+			if file.root != nil && len(file.root.Content) > 0 {
+				unknownKeys(file.root.Content[0], reflect.TypeOf(fileCfg), "", func(key string, line int) {
+					src.problems = append(src.problems, diagnostic{file: path, line: line, field: key, message: "Unknown key"})
+				})
+			}
+
+			dir := filepath.Dir(path)
+			if len(fileCfg.Extends) > 0 {
+				if err = mergeConfigFile(cfg, src, filepath.Join(dir, fileCfg.Extends), loading); err != nil {
+					return err
+				}
+			}
+			for _, include := range fileCfg.Include {
+				if err = mergeConfigFile(cfg, src, filepath.Join(dir, include), loading); err != nil {
+					return err
+				}
+			}
+
+			mergeConfig(cfg, &fileCfg, keys, func(dstPath, srcPath string) {
+				src.origins[dstPath] = settingOrigin{file: file, path: srcPath}
+			})
+		}
+	}
+	if err != nil {
+		d := parseError(path, data, err)
+		log.Print("Error parsing config file ", d)
+		return d
+	}
+
+	return nil
+}
+
+var (
+	// configErrorLine matches the line given in the errors of the YAML and TOML parsers
+	configErrorLine = regexp.MustCompile(`yaml: line (\d+):|^\((\d+), \d+\):`)
+	// configErrorField matches the setting given in the errors of decoding the values
+	configErrorField = regexp.MustCompile(`Go struct field \w+\.(\S+) of type`)
+)
+
+// parseError returns the error of parsing a configuration file as a diagnostic,
+// located at the line of the problem, or of the setting that could not be decoded
+func parseError(path string, data []byte, err error) diagnostic {
+	d := diagnostic{file: path, message: err.Error()}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		d.line = offsetLine(data, syntaxErr.Offset)
+
+	case errors.As(err, &typeErr):
+		d.line = offsetLine(data, typeErr.Offset)
+
+	default:
+		if m := configErrorLine.FindStringSubmatch(d.message); m != nil {
+			d.line, _ = strconv.Atoi(m[1] + m[2])
+		} else if m := configErrorField.FindStringSubmatch(d.message); m != nil {
+			d.line = parseConfigFile(path, data).line(m[1])
+		}
+	}
+
+	return d
+}
+
+// offsetLine returns the line of the byte at offset in data
+func offsetLine(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+
+	return bytes.Count(data[:offset], []byte{'\n'}) + 1
+}
+
+// unmarshalConfig parses the content of a configuration file into v, based on the file extension
+func unmarshalConfig(data []byte, ext string, v interface{}) error {
+	switch configFileType := strings.ToLower(ext); configFileType {
+	case ".json":
+		return json.Unmarshal(data, v)
+
+	case ".yaml", ".yml":
+		return yaml.Unmarshal(data, v)
+
+	case ".toml":
+		tree, err := toml.LoadBytes(data)
+		if err != nil {
+			return err
+		}
+		// TOML has no null, a setting not set, like an unset 'replace', is absent
+		// The values are then read as YAML is, converted to the type of the settings
+		jsonData, err := json.Marshal(tree.ToMap())
+		if err != nil {
+			return err
+		}
+		return yaml.Unmarshal(jsonData, v)
+
+	default:
+		return fmt.Errorf("Unknown config file type '%s'", configFileType)
+	}
+}
+
+// mergeConfig merges src into dst, keys being the settings present in the file src was read from
+// Slices are appended, maps are merged, FilterOptions are merged as lists and
+// other fields are overridden if present in keys
+// record is called with the path of each setting merged, in dst and in src
+func mergeConfig(dst, src *AppConfig, keys map[string]interface{}, record func(dstPath, srcPath string)) {
+	dstValue := reflect.ValueOf(dst).Elem()
+	srcValue := reflect.ValueOf(src).Elem()
+	filterType := reflect.TypeOf(FilterOptions{})
+
+	for i := 0; i < dstValue.NumField(); i++ {
+		field := dstValue.Type().Field(i)
+		key := strings.Split(field.Tag.Get("json"), ",")[0]
+		if key == "extends" || key == "include" {
+			continue
+		}
+		d, s := dstValue.Field(i), srcValue.Field(i)
+
+		switch {
+		case field.Type.Kind() == reflect.Slice:
+			recordList(record, key, d.Len(), s.Len())
+			d.Set(reflect.AppendSlice(d, s))
+
+		case field.Type.Kind() == reflect.Map:
+			if s.Len() > 0 && d.IsNil() {
+				d.Set(reflect.MakeMap(field.Type))
+			}
+			for _, k := range s.MapKeys() {
+				record(key+"."+k.String(), key+"."+k.String())
+				d.SetMapIndex(k, s.MapIndex(k))
+			}
+
+		case field.Type == filterType:
+			df := d.Addr().Interface().(*FilterOptions)
+			sf := s.Interface().(FilterOptions)
+			recordList(record, key+".include", len(df.Include), len(sf.Include))
+			recordList(record, key+".exclude", len(df.Exclude), len(sf.Exclude))
+			df.Include = append(df.Include, sf.Include...)
+			df.Exclude = append(df.Exclude, sf.Exclude...)
+
+		default:
+			if _, ok := keys[key]; ok {
+				record(key, key)
+				d.Set(s)
+			}
+		}
+	}
+}
+
+// recordList records the origin of the n elements of a list appended to a list of length at
+func recordList(record func(dstPath, srcPath string), key string, at, n int) {
+	for i := 0; i < n; i++ {
+		record(key+"["+strconv.Itoa(at+i)+"]", key+"["+strconv.Itoa(i)+"]")
+	}
+}
+
+// parseConfigFile parses the YAML document of a configuration file, to locate its settings
+// JSON is YAML, once the tabs used as indentation are replaced
+// (a tab can only be whitespace in JSON, as tabs in strings are escaped)
+// TOML documents are converted, keeping the lines of the keys
+func parseConfigFile(path string, data []byte) *configFile {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		data = bytes.Replace(data, []byte{'\t'}, []byte{' '}, -1)
+
+	case ".toml":
+		tree, err := toml.LoadBytes(data)
+		if err != nil {
+			return &configFile{path: path}
+		}
+		root := &yamlv3.Node{Kind: yamlv3.DocumentNode, Content: []*yamlv3.Node{tomlNode(tree, 1)}}
+		return &configFile{path: path, root: root}
+	}
+
+	var root yamlv3.Node
+	if err := yamlv3.Unmarshal(data, &root); err != nil || root.Kind != yamlv3.DocumentNode {
+		return &configFile{path: path}
+	}
+
+	return &configFile{path: path, root: &root}
+}
+
+// tomlNode returns the YAML node of a TOML value, found at line
+func tomlNode(value interface{}, line int) *yamlv3.Node {
+	switch v := value.(type) {
+	case *toml.Tree:
+		node := &yamlv3.Node{Kind: yamlv3.MappingNode, Line: line}
+		if pos := v.Position(); !pos.Invalid() {
+			node.Line = pos.Line
+		}
+
+		keys := v.Keys()
+		sort.Slice(keys, func(i, j int) bool {
+			return v.GetPosition(keys[i]).Line < v.GetPosition(keys[j]).Line
+		})
+		for _, key := range keys {
+			keyLine := v.GetPositionPath([]string{key}).Line
+			node.Content = append(node.Content,
+				&yamlv3.Node{Kind: yamlv3.ScalarNode, Value: key, Line: keyLine},
+				tomlNode(v.GetPath([]string{key}), keyLine))
+		}
+		return node
+
+	case []*toml.Tree:
+		node := &yamlv3.Node{Kind: yamlv3.SequenceNode, Line: line}
+		for _, item := range v {
+			node.Content = append(node.Content, tomlNode(item, line))
+		}
+		return node
+
+	case []interface{}:
+		node := &yamlv3.Node{Kind: yamlv3.SequenceNode, Line: line}
+		for _, item := range v {
+			node.Content = append(node.Content, tomlNode(item, line))
+		}
+		return node
+	}
+
+	return &yamlv3.Node{Kind: yamlv3.ScalarNode, Value: fmt.Sprint(value), Line: line}
+}
+
+// line returns the line of the setting at path in the file, e.g. patterns[1].search
+// If the setting is not in the file, the line of the closest enclosing setting is returned
+// 0 is returned if the file could not be parsed
+func (f *configFile) line(path string) int {
+	if f.root == nil || len(f.root.Content) == 0 {
+		return 0
+	}
+
+	node := f.root.Content[0]
+	for _, name := range splitSettingPath(path) {
+		var next *yamlv3.Node
+		switch node.Kind {
+		case yamlv3.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if strings.EqualFold(node.Content[i].Value, name) {
+					next = node.Content[i+1]
+					break
+				}
+			}
+
+		case yamlv3.SequenceNode:
+			if i, err := strconv.Atoi(name); err == nil && i >= 0 && i < len(node.Content) {
+				next = node.Content[i]
+			}
+		}
+		if next == nil {
+			break
+		}
+		node = next
+	}
+
+	return node.Line
+}
+
+// splitSettingPath returns the keys and indexes of the path of a setting
+// e.g. patterns[1].filter.include[0] gives patterns, 1, filter, include and 0
+func splitSettingPath(path string) []string {
+	return strings.FieldsFunc(path, func(r rune) bool {
+		return r == '.' || r == '[' || r == ']'
+	})
+}
+
+// locate returns the file, the path in the file and the line of the setting at path
+// in the merged configuration. The file is empty if the setting was not read from a file
+func (src *configSource) locate(path string) (string, string, int) {
+	if src == nil {
+		return "", path, 0
+	}
+
+	// The origin of a list element or of a setting covers what it contains
+	for end := len(path); end > 0; end = strings.LastIndexAny(path[:end], ".[") {
+		if origin, ok := src.origins[path[:end]]; ok {
+			filePath := origin.path + path[end:]
+			return origin.file.path, filePath, origin.file.line(filePath)
+		}
+	}
+
+	return "", path, 0
+}
+
+// diagnostic returns the problem with the setting at path in the merged configuration,
+// located in the file it was read from
+func (src *configSource) diagnostic(path, message string) diagnostic {
+	file, filePath, line := src.locate(path)
+	return diagnostic{file: file, line: line, field: filePath, message: message}
+}
+
+// override records that the setting at path was given on the command line
+func (src *configSource) override(path string) {
+	if src != nil {
+		delete(src.origins, path)
+	}
+}
+
+// unknownKeys calls report with the path and the line of the keys of node that
+// are not settings of type t, and of the values it contains
+func unknownKeys(node *yamlv3.Node, t reflect.Type, path string, report func(key string, line int)) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	// Types reading their own JSON, like StringOption, have no keys
+	if reflect.PtrTo(t).Implements(reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()) {
+		return
+	}
+
+	switch {
+	case t.Kind() == reflect.Struct && node.Kind == yamlv3.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			keyPath := key.Value
+			if len(path) > 0 {
+				keyPath = path + "." + key.Value
+			}
+
+			field, ok := fieldByKey(t, key.Value)
+			if !ok {
+				report(keyPath, key.Line)
+				continue
+			}
+			unknownKeys(node.Content[i+1], field.Type, keyPath, report)
+		}
+
+	case t.Kind() == reflect.Slice && node.Kind == yamlv3.SequenceNode:
+		for i, item := range node.Content {
+			unknownKeys(item, t.Elem(), path+"["+strconv.Itoa(i)+"]", report)
+		}
+
+	case t.Kind() == reflect.Map && node.Kind == yamlv3.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			unknownKeys(node.Content[i+1], t.Elem(), path+"."+node.Content[i].Value, report)
+		}
+	}
+}
+
+// fieldByKey returns the field of the struct type t read from key, ignoring case as encoding/json does
+func fieldByKey(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if len(name) == 0 {
+			name = field.Name
+		}
+		if field.PkgPath == "" && name != "-" && strings.EqualFold(name, key) {
+			return field, true
+		}
+	}
+
+	return reflect.StructField{}, false
+}
+
+// templateConfig returns the sample configuration in the format given by the extension of path
+// Only the YAML sample has comments, the JSON and TOML samples hold the same settings
+func templateConfig(path string) ([]byte, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".yaml" || ext == ".yml" {
+		return templateConfigData, nil
+	}
+
+	// The settings are read into AppConfig for their types (e.g. occurrences is a string),
+	// and only the ones set in the sample are kept
+	var cfg AppConfig
+	var present map[string]interface{}
+	if err := yaml.Unmarshal(templateConfigData, &cfg); err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(templateConfigData, &present); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(&cfg)
+	if err != nil {
+		return nil, err
+	}
+	var settings map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err = decoder.Decode(&settings); err != nil {
+		return nil, err
+	}
+	presentSettings(settings, present)
+
+	switch ext {
+	case ".json":
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(settings)
+		return buf.Bytes(), err
+
+	case ".toml":
+		tree, err := toml.TreeFromMap(settings)
+		if err != nil {
+			return nil, err
+		}
+		text, err := tree.ToTomlString()
+		return []byte(text), err
+
+	default:
+		return nil, fmt.Errorf("Unknown config file type '%s'", ext)
+	}
+}
+
+// presentSettings removes from the settings decoded from JSON those that are not set
+// (or null) in present, and turns the numbers into integers
+func presentSettings(value, present interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		p, _ := present.(map[string]interface{})
+		for key, item := range v {
+			if p[key] == nil {
+				delete(v, key)
+			} else {
+				v[key] = presentSettings(item, p[key])
+			}
+		}
+
+	case []interface{}:
+		p, _ := present.([]interface{})
+		for i := range v {
+			if i < len(p) {
+				v[i] = presentSettings(v[i], p[i])
+			}
+		}
+
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+	}
+
+	return value
+}
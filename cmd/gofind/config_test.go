@@ -0,0 +1,228 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfigFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), os.ModePerm))
+		assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	writeConfigFiles(t, dir, map[string]string{
+		"base/base.yaml": `
+inputDirectory: base
+gitIgnore: true
+lineEndings: crlf
+variables: {company: Foo, year: "2019"}
+fileNamePatterns: {include: ['\.go$']}
+patterns:
+- search: one
+  replace: ONE
+  occurrences: 1
+`,
+		"common/extra.json": `{
+  "variables": {"year": "2020"},
+  "patterns": [{"search": "two", "replace": "TWO"}]
+}`,
+		"repo/gofind.yaml": `
+extends: ../base/base.yaml
+include:
+- ../common/extra.json
+gitIgnore: false
+fileNamePatterns: {include: ['\.c$'], exclude: [vendor]}
+patterns:
+- search: three
+`,
+	})
+
+	cfg, err := loadConfig(filepath.Join(dir, "repo", "gofind.yaml"))
+	assert.NoError(t, err)
+
+	var searches []string
+	for _, p := range cfg.Patterns {
+		searches = append(searches, p.Search)
+	}
+	assert.Equal(t, []string{"one", "two", "three"}, searches)
+	assert.Equal(t, ScalarOption("1"), cfg.Patterns[0].Occurrences)
+	assert.Equal(t, "base", cfg.InputDirectory)
+	assert.False(t, cfg.GitIgnore)
+	assert.Equal(t, "crlf", cfg.LineEndings)
+	assert.Equal(t, map[string]ScalarOption{"company": "Foo", "year": "2020"}, cfg.Variables)
+	assert.Equal(t, FilterOptions{Include: []string{`\.go$`, `\.c$`}, Exclude: []string{"vendor"}}, cfg.FileNames)
+	assert.Empty(t, cfg.Extends)
+	assert.Empty(t, cfg.Include)
+}
+
+func TestLoadConfig_Diamond(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	writeConfigFiles(t, dir, map[string]string{
+		"base.yaml":   "patterns:\n- search: one\n  replace: one1\n",
+		"a.yaml":      "extends: base.yaml\npatterns:\n- search: a\n",
+		"b.yaml":      "extends: base.yaml\npatterns:\n- search: b\n",
+		"gofind.yaml": "include: [a.yaml, b.yaml]\n",
+	})
+
+	// base.yaml is merged once, where a.yaml extends it
+	cfg, err := loadConfig(filepath.Join(dir, "gofind.yaml"))
+	assert.NoError(t, err)
+	var searches []string
+	for _, p := range cfg.Patterns {
+		searches = append(searches, p.Search)
+	}
+	assert.Equal(t, []string{"one", "a", "b"}, searches)
+}
+
+func TestLoadConfig_Errors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	writeConfigFiles(t, dir, map[string]string{
+		"a.yaml":       "include: [b.yaml]\n",
+		"b.yaml":       "extends: sub/c.json\n",
+		"sub/c.json":   `{"extends": "../a.yaml"}`,
+		"missing.yaml": "extends: nowhere.yaml\n",
+		"bad.txt":      "",
+	})
+
+	_, err = loadConfig(filepath.Join(dir, "a.yaml"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cycle")
+		assert.Contains(t, err.Error(), filepath.Join(dir, "sub", "c.json")+" -> "+filepath.Join(dir, "a.yaml"))
+	}
+
+	_, err = loadConfig(filepath.Join(dir, "missing.yaml"))
+	assert.Error(t, err)
+
+	_, err = loadConfig(filepath.Join(dir, "bad.txt"))
+	assert.Error(t, err)
+}
+
+func TestLoadConfig_ParseErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"syntax.yaml": "patterns:\n- search: a\n  occurrences: [1\n",
+		"type.yaml":   "patterns:\n- search: a\ngitIgnore: 'yes'\n",
+		"syntax.json": "{\n\t\"patterns\": [\n\t\t{\"search\": \"a\",}\n\t]\n}\n",
+		"type.json":   "{\n\t\"patterns\": [],\n\t\"gitIgnore\": \"yes\"\n}\n",
+		"syntax.toml": "gitIgnore = true\n[[patterns]]\nsearch =\n",
+		"type.toml":   "[[patterns]]\nsearch = 'a'\n\n[counter]\nstart = 'one'\n",
+	}
+	writeConfigFiles(t, dir, files)
+
+	for name, line := range map[string]int{
+		"syntax.yaml": 3,
+		"type.yaml":   3,
+		"syntax.json": 3,
+		"type.json":   3,
+		"syntax.toml": 4,
+		"type.toml":   5,
+	} {
+		_, err = loadConfig(filepath.Join(dir, name))
+		if assert.IsType(t, diagnostic{}, err, name) {
+			assert.Equal(t, filepath.Join(dir, name), err.(diagnostic).file, name)
+			assert.Equal(t, line, err.(diagnostic).line, name)
+		}
+	}
+}
+
+func TestLoadConfig_TOML(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	writeConfigFiles(t, dir, map[string]string{
+		"base.yml": `
+gitIgnore: true
+patterns:
+- search: one
+  replace: ~
+`,
+		"gofind.toml": `extends = "base.yml"
+inputDirectory = "in"
+
+[counter]
+width = 3
+
+[[patterns]]
+search = "two"
+
+[[patterns]]
+search = "three"
+replace = ""
+occurrences = 1
+
+[[patterns]]
+search = "(four"
+replace = "FOUR"
+occurrences = "all"
+[patterns.filter]
+exclude = ["^//"]
+`,
+	})
+
+	cfg, src, err := loadConfigSource(filepath.Join(dir, "gofind.toml"))
+	assert.NoError(t, err)
+
+	assert.True(t, cfg.GitIgnore)
+	assert.Equal(t, "in", cfg.InputDirectory)
+	assert.Equal(t, 3, cfg.Counter.Width)
+	if assert.Len(t, cfg.Patterns, 4) {
+		// An unset or null replace searches only, an empty one deletes the matches
+		assert.False(t, cfg.Patterns[0].Replace.IsValid())
+		assert.False(t, cfg.Patterns[1].Replace.IsValid())
+		assert.True(t, cfg.Patterns[2].Replace.IsValid())
+		assert.Equal(t, "", cfg.Patterns[2].Replace.String())
+		assert.Equal(t, ScalarOption("1"), cfg.Patterns[2].Occurrences)
+		assert.Equal(t, "FOUR", cfg.Patterns[3].Replace.String())
+		assert.Equal(t, []string{"^//"}, cfg.Patterns[3].Filter.Exclude)
+	}
+
+	// The settings of TOML files are located too
+	assert.Equal(t, []string{
+		filepath.Join(dir, "gofind.toml") + ":16: patterns[2].search: error parsing regexp: missing closing ): `(four`",
+	}, diagnosticStrings(validateConfig(&cfg, src, false)))
+}
+
+func TestTemplateConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	expected, err := loadConfig("testdata/config.yaml")
+	assert.NoError(t, err)
+
+	for _, name := range []string{"gofind.yaml", "gofind.yml", "gofind.json", "gofind.toml"} {
+		data, err := templateConfig(name)
+		assert.NoError(t, err)
+		path := filepath.Join(dir, name)
+		assert.NoError(t, ioutil.WriteFile(path, data, 0644))
+
+		cfg, err := loadConfig(path)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, cfg, name)
+	}
+
+	_, err = templateConfig("gofind.txt")
+	assert.Error(t, err)
+}
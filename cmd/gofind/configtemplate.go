@@ -1,13 +1,50 @@
 package main
+
 // Generated content
 var templateConfigData = []byte(`
+# Other configuration files to merge, relative to this file: first the one named by
+# 'extends', then the ones listed in 'include', then this file. Lists (patterns,
+# filters, globs, etc.) are appended, other settings set by a later file override
+# the earlier ones
+#extends: ../base.yaml
+#include:
+#- common-patterns.yaml
+
+# Variables, used as ${var:NAME} in the search, replace, filter and directory settings
+# ${env:NAME} is replaced by the environment variable NAME, in variables too
+# -var NAME=value on the command line overrides a variable
+# A reference to an undefined variable is an error
+#variables:
+#  company: Foo
+#  home: ${env:HOME}
+
 # Name of the directory to search for files
 inputDirectory: ./testdata/input
 
+# Other directories to search along with inputDirectory
+# Their files are placed in outputDirectory under the name of the directory
+# Files and directories given as command line arguments (or with -files-from)
+# are processed instead of the input directories
+#inputDirectories:
+#- ./testdata/more
+
 # Name of the directory to place the updated files
 # If not provided, the original file will be replaced
 outputDirectory: ./testdata/output
 
+# Copy the modification time of the input files on the updated files
+# The permission bits of the input files are always copied
+preserveTimestamps: false
+
+# Skip the files and directories ignored by the .gitignore files, and the .git directories
+# The .gofindignore files, with the same syntax, are always honored
+gitIgnore: false
+
+# Apply all the patterns on binary files too
+# A file is binary if its first block has a NUL byte or too many invalid UTF-8 sequences
+# By default, binary files are skipped unless a pattern has 'binary: true'
+binaryFiles: false
+
 # Regular expressions to select the files based on their name
 # Default is to select all files
 #
@@ -25,6 +62,43 @@ fileNamePatterns:
   exclude:
   - .*\.ex$
 
+# Glob patterns to select the files based on their path relative to inputDirectory
+# Used along with the fileNamePatterns; a file is selected if any one of the
+# 'include' patterns or globs match, and none of the 'exclude' ones
+#
+# '**' matches across directories, a glob without a '/' matches the file name only
+# and a glob starting with '!' ignores the matching files and directories
+# (quote it in YAML). Entries in fileNamePatterns starting with 'glob:' are globs too
+#fileGlobs:
+#- "**/*.in"
+#- "!vendor/**"
+
+# Character encoding of the files, decoded to UTF-8 before applying the patterns
+# and encoded back when written. The first rule whose fileNamePatterns match applies
+# Files starting with a byte order mark (BOM) are detected regardless, and keep it
+# Default is UTF-8. Supported: utf-8, utf-16le, utf-16be, utf-16, iso-8859-1 (latin1)
+#encodings:
+#- encoding: utf-16le
+#  fileNamePatterns:
+#    include:
+#    - \.rc$
+
+# Line endings of the files: lf, crlf or preserve (default)
+# With lf or crlf, all the line endings of the files processed are converted, even
+# if no pattern matches. Either way, new lines in the replacement text are converted
+# to the line ending of the file (the one used by most of its lines, for preserve)
+lineEndings: preserve
+
+# Sequence of numbers for the replacements, as ${#counter} in 'replace'
+# It goes on across all the files of a run; 'width' pads the numbers with zeros
+# 'replace' can also use ${#index}, the number of the replacement in the file
+# (counting all the patterns), and ${#occurrence}, the number of the match among
+# the matches of the pattern in the file. They all start from the first match
+#counter:
+#  start: 1
+#  step: 1
+#  width: 3
+
 # Regular expressions to select the files based on their content
 # Default is to select all files
 #
@@ -42,6 +116,7 @@ filter:
   - ^// Skip This.*
 
 # Search Replace patterns
+# Add 'binary: true' to a pattern to apply it on binary files as well
 patterns:
   # Search and replace all 'one's with 'ONE'
   - search: one
@@ -59,4 +134,30 @@ patterns:
       include:
       exclude:
       - ^// Copyright.* # Skip adding header if file already has a header
+
+  # Options for a pattern, all false by default:
+  # 'literal' searches for the text as it is instead of a regular expression,
+  # 'ignoreCase' ignores the case of letters, 'wholeWord' skips matches inside
+  # longer words and 'replaceLiteral' does not expand $1, ${name}, etc. in 'replace'
+  # The one below replaces 'Price($)' and 'price($)' but not 'UnitPrice($)'
+  #- search: price($)
+  #  literal: true
+  #  ignoreCase: true
+  #  wholeWord: true
+  #  replace: cost($)
+  #  replaceLiteral: true
+
+  # 'replaceTemplate' computes the replacement with a Go text/template instead of 'replace'
+  # It has .Match, the text of the match, .Groups, the text of the match followed by the
+  # capture groups, and each named capture group by its name. Along with the builtin
+  # functions (printf, index, etc.) it can use upper, lower, title, trim, camel (getUserName),
+  # pascal (GetUserName), snake (get_user_name) and kebab (get-user-name)
+  #- search: func (?P<name>[a-z_]+)\(
+  #  replaceTemplate: 'func {{pascal .name}}('
+
+  # 'preserveCase' matches regardless of case and gives the replacement the case of
+  # each match: widget -> gadget, Widget -> Gadget, WIDGET -> GADGET, widgetId -> gadgetId
+  #- search: widget
+  #  replace: gadget
+  #  preserveCase: true
 `)
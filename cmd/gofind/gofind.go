@@ -1,18 +1,22 @@
 package main
 
 import (
-	"encoding/json"
+	"bytes"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
-	"github.com/ghodss/yaml"
 	"github.com/prijip/gofind"
 )
 
@@ -26,25 +30,89 @@ type FilterOptions struct {
 type SearchReplaceOption struct {
 	Search      string        `json:"search"`
 	Replace     StringOption  `json:"replace"`
-	Occurrences string        `json:"occurrences"`
+	Occurrences ScalarOption  `json:"occurrences"`
 	Filter      FilterOptions `json:"filter"`
+	Binary      bool          `json:"binary"`
+
+	// Literal searches for the text as it is, instead of a regular expression
+	Literal bool `json:"literal"`
+	// IgnoreCase matches regardless of the case of letters
+	IgnoreCase bool `json:"ignoreCase"`
+	// WholeWord matches only where the text is not part of a longer word
+	WholeWord bool `json:"wholeWord"`
+	// ReplaceLiteral uses the replacement text as it is, without expanding $1, ${name}, etc.
+	ReplaceLiteral bool `json:"replaceLiteral"`
+	// ReplaceTemplate is a text/template computing the replacement, used instead of Replace
+	ReplaceTemplate string `json:"replaceTemplate"`
+	// PreserveCase matches regardless of case and adapts the case of the replacement to each match
+	PreserveCase bool `json:"preserveCase"`
+}
+
+// EncodingOption sets the character encoding of the files whose name match the patterns
+type EncodingOption struct {
+	Encoding  string        `json:"encoding"`
+	FileNames FilterOptions `json:"fileNamePatterns"`
+}
+
+// CounterOption configures the sequence of numbers available to the replacements as ${#counter}
+type CounterOption struct {
+	Start int `json:"start"`
+	Step  int `json:"step"`
+	Width int `json:"width"`
 }
 
 // AppConfig stores the application configuration
 type AppConfig struct {
-	Patterns        []SearchReplaceOption `json:"patterns"`
-	InputDirectory  string                `json:"inputDirectory"`
-	OutputDirectory string                `json:"outputDirectory"`
-	FileNames       FilterOptions         `json:"fileNamePatterns"`
-	Filter          FilterOptions         `json:"filter"`
+	Patterns         []SearchReplaceOption   `json:"patterns"`
+	InputDirectory   string                  `json:"inputDirectory"`
+	InputDirectories []string                `json:"inputDirectories"`
+	OutputDirectory  string                  `json:"outputDirectory"`
+	FileNames        FilterOptions           `json:"fileNamePatterns"`
+	FileGlobs        []string                `json:"fileGlobs"`
+	GitIgnore        bool                    `json:"gitIgnore"`
+	BinaryFiles      bool                    `json:"binaryFiles"`
+	Encodings        []EncodingOption        `json:"encodings"`
+	LineEndings      string                  `json:"lineEndings"`
+	Counter          CounterOption           `json:"counter"`
+	Variables        map[string]ScalarOption `json:"variables"`
+	Extends          string                  `json:"extends"`
+	Include          []string                `json:"include"`
+	Filter           FilterOptions           `json:"filter"`
+
+	PreserveTimestamps bool `json:"preserveTimestamps"`
 }
 
 var (
 	config         AppConfig
+	configSrc      *configSource
 	searchPattern  string
 	replacePattern StringOption
 	occurrences    string
+	literal        bool
+	ignoreCase     bool
+	wholeWord      bool
+	replaceLiteral bool
 	showVersion    bool
+	dryRun         bool
+	checkOnly      bool
+	interactive    bool
+	jobs           int
+	streamSize     int64
+	maxMatchSpan   int
+	journalDir     string
+	noJournal      bool
+	preserveTimes  bool
+	reportFormat   string
+	grepMode       bool
+	gitIgnore      bool
+	binaryFiles    bool
+	lineEndings    string
+	filesFrom      string
+	cliVars        = varsFlag{}
+	inputPaths     []string
+	contextBefore  int
+	contextAfter   int
+	contextLines   int
 
 	configFileName         string
 	inputDirectory         string
@@ -53,6 +121,15 @@ var (
 	generateConfigFileName string
 )
 
+// Exit codes
+const (
+	exitOK = 0
+	// exitError is returned when the run could not be completed
+	exitError = 1
+	// exitCheckFailed is returned in check mode if any file would be updated
+	exitCheckFailed = 3
+)
+
 // Version and Build Date set externally during linking
 var (
 	Version   = "undefined"
@@ -62,17 +139,50 @@ var (
 func init() {
 	flag.Usage = printUsage
 
-	flag.StringVar(&configFileName, "config", "", "Configuration File Name (JSON/YAML)")
+	flag.StringVar(&configFileName, "config", "", "Configuration File Name (JSON/YAML/TOML)")
 	flag.StringVar(&searchPattern, "search", "", "Regular expression to search for")
 	flag.Var(&replacePattern, "replace", "String to replace with")
+	flag.BoolVar(&literal, "literal", false, "Search for the text given with -search as it is, instead of a regular expression")
+	flag.BoolVar(&ignoreCase, "ignore-case", false, "Match the -search pattern regardless of the case of letters")
+	flag.BoolVar(&wholeWord, "word", false, "Match the -search pattern only as a whole word")
+	flag.BoolVar(&replaceLiteral, "replace-literal", false, "Use the -replace text as it is, without expanding $1, ${name}, etc.")
+	flag.Var(cliVars, "var", "Set a variable used as ${var:key} in the configuration, as key=value. Can be repeated")
 	flag.StringVar(&occurrences, "occurrences", "", "Number of occurrences to be replaced. Default is all occurrences")
-	flag.StringVar(&fileNameIncludePattern, "files", "", "Filename pattern")
+	flag.StringVar(&fileNameIncludePattern, "files", "", "Filename pattern. Prefix with 'glob:' for a glob pattern")
 	flag.StringVar(&inputDirectory, "in-dir", "", "Input Directory")
+	flag.StringVar(&filesFrom, "files-from", "", "Read the files to process from a newline or NUL delimited list in a file, '-' for stdin")
+	flag.BoolVar(&gitIgnore, "gitignore", false, "Skip the files ignored by the .gitignore files, and the .git directories")
 	flag.StringVar(&outputDirectory, "out-dir", "", "Output Directory")
-	flag.StringVar(&generateConfigFileName, "generate-config", "", "Generate sample configuration file")
+	flag.StringVar(&generateConfigFileName, "generate-config", "", "Generate sample configuration file, in JSON, YAML or TOML format according to its extension")
+	flag.BoolVar(&dryRun, "dry-run", false, "Print a unified diff of the changes to stdout without writing any file")
+	flag.BoolVar(&binaryFiles, "binary", false, "Apply all the patterns on binary files too. By default binary files are skipped")
+	flag.StringVar(&lineEndings, "line-endings", "", "Convert the line endings of the files processed (lf|crlf|preserve)")
+	flag.BoolVar(&checkOnly, "check", false, "List the files that would be updated without writing them. Exit with status 3 if there is any")
+	flag.BoolVar(&interactive, "interactive", false, "Ask for confirmation before replacing each match, not with -files-from -")
+	flag.IntVar(&jobs, "jobs", 1, "Number of files to process in parallel. 0 uses the number of CPUs")
+	flag.Int64Var(&streamSize, "stream-threshold", 64*1024*1024, "Size in bytes above which a file is processed as a stream instead of being loaded in memory. 0 disables streaming")
+	flag.IntVar(&maxMatchSpan, "max-match-span", gofind.DefaultMaxMatchSpan, "Length in bytes of the longest match expected, when a file is processed as a stream")
+	flag.StringVar(&journalDir, "journal-dir", defaultJournalDir(), "Directory to keep the journal of each run, used by 'gofind undo'")
+	flag.BoolVar(&noJournal, "no-journal", false, "Do not keep a journal of the files written")
+	flag.BoolVar(&preserveTimes, "preserve-timestamps", false, "Copy the modification time of the input files on the updated files")
+	flag.StringVar(&reportFormat, "report", "", "Write the outcome of each file to stdout in a machine readable format (json|ndjson), not with -dry-run, -check or -grep")
+	flag.BoolVar(&grepMode, "grep", false, "Search only. Print the matches of all the patterns as path:line:column: text, without replacing")
+	flag.IntVar(&contextAfter, "A", 0, "Number of lines to print after each match, in search only mode")
+	flag.IntVar(&contextBefore, "B", 0, "Number of lines to print before each match, in search only mode")
+	flag.IntVar(&contextLines, "C", 0, "Number of lines to print before and after each match, in search only mode")
 	flag.BoolVar(&showVersion, "version", false, "Show version and exit")
 }
 
+// defaultJournalDir returns the directory to keep the run journals in the user cache
+func defaultJournalDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+
+	return filepath.Join(dir, "gofind", "journal")
+}
+
 func printVersion() {
 	fmt.Fprintln(flag.CommandLine.Output(),
 		"gofind version", Version, "build date", BuildDate)
@@ -82,8 +192,19 @@ func printUsage() {
 	printVersion()
 
 	fmt.Fprintln(flag.CommandLine.Output(),
-		"gofind -config <path/to/configfile> [over-ride options]")
-	fmt.Fprintln(flag.CommandLine.Output(), "configfile can be in JSON or YAML format")
+		"gofind -config <path/to/configfile> [over-ride options] [file or directory ...]")
+	fmt.Fprintln(flag.CommandLine.Output(), "configfile can be in JSON, YAML or TOML format (.json, .yaml or .yml, .toml)")
+	fmt.Fprintln(flag.CommandLine.Output(),
+		"gofind -config <path/to/configfile> [over-ride options] -")
+	fmt.Fprintln(flag.CommandLine.Output(), "Apply the patterns on stdin and write the result to stdout")
+	fmt.Fprintln(flag.CommandLine.Output(),
+		"gofind -config <path/to/configfile> [over-ride options] validate")
+	fmt.Fprintln(flag.CommandLine.Output(), "Report the problems of the configuration, with their file and line")
+	fmt.Fprintln(flag.CommandLine.Output(), "gofind schema")
+	fmt.Fprintln(flag.CommandLine.Output(), "Print the JSON Schema of the configuration files")
+	fmt.Fprintln(flag.CommandLine.Output(),
+		"gofind [-journal-dir <path/to/journals>] undo [run-id]")
+	fmt.Fprintln(flag.CommandLine.Output(), "Restore the files written by a run (default: the last run)")
 
 	fmt.Fprintln(flag.CommandLine.Output(), "")
 
@@ -100,27 +221,11 @@ func parseFlags() error {
 	flag.Parse()
 
 	config = AppConfig{}
+	configSrc = nil
 	// If a config file is specified, load it
 	if len(configFileName) > 0 {
-		configData, err := ioutil.ReadFile(configFileName)
-		if err != nil {
-			log.Printf("Error reading config file %s. err=%v", configFileName, err)
-			return err
-		}
-
-		switch configFileType := strings.ToLower(filepath.Ext(configFileName)); configFileType {
-		case ".json":
-			err = json.Unmarshal(configData, &config)
-
-		case ".yaml":
-			err = yaml.Unmarshal(configData, &config)
-
-		default:
-			err = fmt.Errorf("Unknown config file type '%s'", configFileType)
-		}
-
-		if err != nil {
-			log.Printf("Error parsing config file %s. err=%v", configFileName, err)
+		var err error
+		if config, configSrc, err = loadConfigSource(configFileName); err != nil {
 			return err
 		}
 	}
@@ -130,7 +235,12 @@ func parseFlags() error {
 		pattern := SearchReplaceOption{
 			Search:      searchPattern,
 			Replace:     replacePattern,
-			Occurrences: occurrences,
+			Occurrences: ScalarOption(occurrences),
+
+			Literal:        literal,
+			IgnoreCase:     ignoreCase,
+			WholeWord:      wholeWord,
+			ReplaceLiteral: replaceLiteral,
 		}
 
 		config.Patterns = append(config.Patterns, pattern)
@@ -138,6 +248,7 @@ func parseFlags() error {
 
 	if len(inputDirectory) > 0 {
 		config.InputDirectory = inputDirectory
+		configSrc.override("inputDirectory")
 	}
 
 	if len(fileNameIncludePattern) > 0 {
@@ -148,18 +259,63 @@ func parseFlags() error {
 		config.OutputDirectory = outputDirectory
 	}
 
+	if preserveTimes {
+		config.PreserveTimestamps = true
+	}
+
+	if gitIgnore {
+		config.GitIgnore = true
+	}
+
+	if binaryFiles {
+		config.BinaryFiles = true
+	}
+
+	if len(lineEndings) > 0 {
+		config.LineEndings = lineEndings
+		configSrc.override("lineEndings")
+	}
+
+	// Undefined variables are reported along with the other problems of the configuration
+	if configSrc == nil {
+		configSrc = &configSource{}
+	}
+	configSrc.problems = append(configSrc.problems, substituteVariables(&config, cliVars, configSrc)...)
+
 	if len(config.OutputDirectory) == 0 {
 		config.OutputDirectory = config.InputDirectory
 	}
 
+	// Files and directories given as arguments are processed instead of the input directories
+	args := flag.Args()
+	if len(args) > 0 && (args[0] == "undo" || args[0] == "validate" || args[0] == "schema" || args[0] == "-") {
+		args = nil
+	}
+	var err error
+	if inputPaths, err = readInputPaths(args, filesFrom, os.Stdin); err != nil {
+		log.Printf("Error reading the list of files %s. err=%v", filesFrom, err)
+		return err
+	}
+
 	return nil
 }
 
 func validateFlags() error {
-	if len(config.Patterns) == 0 || len(config.InputDirectory) == 0 {
+	if len(config.Patterns) == 0 || len(config.InputDirectory)+len(config.InputDirectories)+len(inputPaths) == 0 {
 		return fmt.Errorf("Incorrect Usage")
 	}
 
+	// The report is written to stdout, where it would be mixed with the diffs, the list of files or the matches
+	// Matches found with -grep are not reported either
+	if len(reportFormat) > 0 && (dryRun || checkOnly || grepMode) {
+		return fmt.Errorf("-report cannot be combined with -dry-run, -check or -grep")
+	}
+
+	// The answers are read from stdin, which already held the list of files
+	if interactive && filesFrom == "-" {
+		return fmt.Errorf("-interactive cannot be combined with -files-from -")
+	}
+
 	return nil
 }
 
@@ -196,12 +352,109 @@ func filterPatternsFromOptions(options FilterOptions) (patterns gofind.Filter, e
 	return
 }
 
+// globPrefix marks a file name pattern as a glob instead of a regular expression
+const globPrefix = "glob:"
+
+// fileNameFilterFromOptions compiles the file name patterns and the globs
+// File name patterns with the globPrefix are globs; globs starting with '!' are exclusions
+func fileNameFilterFromOptions(options FilterOptions, globs []string) (patterns gofind.Filter, err error) {
+	var regexOptions FilterOptions
+	var includeGlobs, excludeGlobs []string
+
+	for _, include := range options.Include {
+		if strings.HasPrefix(include, globPrefix) {
+			includeGlobs = append(includeGlobs, strings.TrimPrefix(include, globPrefix))
+		} else {
+			regexOptions.Include = append(regexOptions.Include, include)
+		}
+	}
+
+	for _, exclude := range options.Exclude {
+		if strings.HasPrefix(exclude, globPrefix) {
+			excludeGlobs = append(excludeGlobs, strings.TrimPrefix(exclude, globPrefix))
+		} else {
+			regexOptions.Exclude = append(regexOptions.Exclude, exclude)
+		}
+	}
+
+	for _, glob := range globs {
+		if strings.HasPrefix(glob, "!") {
+			excludeGlobs = append(excludeGlobs, glob[1:])
+		} else {
+			includeGlobs = append(includeGlobs, glob)
+		}
+	}
+
+	patterns, err = filterPatternsFromOptions(regexOptions)
+	if err != nil {
+		return
+	}
+
+	for _, glob := range includeGlobs {
+		var g *gofind.Glob
+		if g, err = gofind.CompileGlob(glob); err != nil {
+			log.Print(err)
+			return
+		}
+		patterns.IncludeGlobs = append(patterns.IncludeGlobs, g)
+	}
+
+	for _, glob := range excludeGlobs {
+		var g *gofind.Glob
+		if g, err = gofind.CompileGlob(glob); err != nil {
+			log.Print(err)
+			return
+		}
+		patterns.ExcludeGlobs = append(patterns.ExcludeGlobs, g)
+	}
+
+	return
+}
+
+// searchRegexFromOption compiles the search text, according to the literal,
+// ignoreCase and wholeWord options
+func searchRegexFromOption(option *SearchReplaceOption) (*regexp.Regexp, error) {
+	expr := option.Search
+	if option.Literal {
+		expr = regexp.QuoteMeta(expr)
+
+		// A word boundary is only meaningful next to a word character
+		if option.WholeWord && len(option.Search) > 0 {
+			if isWordByte(option.Search[0]) {
+				expr = `\b` + expr
+			}
+			if isWordByte(option.Search[len(option.Search)-1]) {
+				expr += `\b`
+			}
+		}
+	} else if option.WholeWord {
+		expr = `\b(?:` + expr + `)\b`
+	}
+
+	if option.IgnoreCase || option.PreserveCase {
+		expr = "(?i)" + expr
+	}
+
+	return regexp.Compile(expr)
+}
+
+// isWordByte reports whether c is a word character, as matched by \w
+func isWordByte(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
 func searchReplacePatternsFromOptions(options []SearchReplaceOption) []gofind.SearchReplacePattern {
 	var patterns []gofind.SearchReplacePattern
+	// All the patterns share the counter of the run
+	counter := &gofind.Counter{
+		Start: config.Counter.Start,
+		Step:  config.Counter.Step,
+		Width: config.Counter.Width,
+	}
 
 	// Compile the search text patterns
 	for i := range options {
-		searchRegex, err := regexp.Compile(options[i].Search)
+		searchRegex, err := searchRegexFromOption(&options[i])
 		if err != nil {
 			log.Print("Failed to compile regex: ", options[i].Search)
 			return nil
@@ -209,18 +462,15 @@ func searchReplacePatternsFromOptions(options []SearchReplaceOption) []gofind.Se
 		var replacePattern []byte
 		if options[i].Replace.IsValid() {
 			replacePattern = []byte(config.Patterns[i].Replace.String())
+			if options[i].ReplaceLiteral {
+				replacePattern = literalReplacePattern(replacePattern)
+			}
 		}
 
-		occOpt := options[i].Occurrences
-		occInt := -1
-		if len(occOpt) == 0 || occOpt == "all" {
-			occInt = -1
-		} else {
-			if v, err := strconv.ParseInt(occOpt, 10, 32); err != nil {
-				log.Printf("Error parsing occurrences: err=%v", err)
-			} else {
-				occInt = int(v)
-			}
+		occInt, err := parseOccurrences(string(options[i].Occurrences))
+		if err != nil {
+			log.Print(err)
+			return nil
 		}
 
 		filter, err := filterPatternsFromOptions(options[i].Filter)
@@ -234,6 +484,18 @@ func searchReplacePatternsFromOptions(options []SearchReplaceOption) []gofind.Se
 			ReplacePattern: replacePattern,
 			Occurrences:    occInt,
 			Filter:         &filter,
+			Binary:         options[i].Binary,
+			PreserveCase:   options[i].PreserveCase,
+			Counter:        counter,
+		}
+
+		if len(options[i].ReplaceTemplate) > 0 {
+			pattern.ReplacePattern = nil
+			pattern.ReplaceFunc, err = replaceFuncFromTemplate(options[i].ReplaceTemplate, searchRegex)
+			if err != nil {
+				log.Printf("Failed to parse replace template of '%s'. err=%v", options[i].Search, err)
+				return nil
+			}
 		}
 		patterns = append(patterns, pattern)
 	}
@@ -241,93 +503,577 @@ func searchReplacePatternsFromOptions(options []SearchReplaceOption) []gofind.Se
 	return patterns
 }
 
-func fileHandler(patterns []gofind.SearchReplacePattern, fnFilter, filter *gofind.Filter, updatedFiles *[]string) filepath.WalkFunc {
+// literalReplaceVars are the variables still expanded in a literal replacement text
+var literalReplaceVars = [][]byte{[]byte(gofind.VarIndex), []byte(gofind.VarOccurrence), []byte(gofind.VarCounter)}
+
+// literalReplacePattern escapes each "$" of the replacement text as "$$", that expands
+// to a single "$", except in the variables ${#index}, ${#occurrence} and ${#counter}
+func literalReplacePattern(text []byte) []byte {
+	var escaped []byte
+next:
+	for len(text) > 0 {
+		i := bytes.IndexByte(text, '$')
+		if i < 0 {
+			escaped = append(escaped, text...)
+			break
+		}
+		escaped = append(escaped, text[:i]...)
+		text = text[i:]
+
+		for _, v := range literalReplaceVars {
+			if bytes.HasPrefix(text, v) {
+				escaped = append(escaped, v...)
+				text = text[len(v):]
+				continue next
+			}
+		}
+		escaped = append(escaped, '$', '$')
+		text = text[1:]
+	}
+
+	return escaped
+}
+
+// parseOccurrences returns the number of occurrences to replace, -1 for all of them
+// The option is empty, "all" or a negative number for all the occurrences
+func parseOccurrences(occurrences string) (int, error) {
+	if len(occurrences) == 0 || occurrences == "all" {
+		return -1, nil
+	}
+
+	v, err := strconv.ParseInt(occurrences, 10, 32)
+	if err != nil {
+		return -1, fmt.Errorf("Invalid occurrences '%s', expected 'all' or a number", occurrences)
+	}
+	if v < 0 {
+		return -1, nil
+	}
+
+	return int(v), nil
+}
+
+// encodingRule is a compiled EncodingOption
+type encodingRule struct {
+	encoding *gofind.Encoding
+	filter   gofind.Filter
+}
+
+// encodingRulesFromOptions looks up the encodings and compiles their file name patterns
+func encodingRulesFromOptions(options []EncodingOption) ([]encodingRule, error) {
+	var rules []encodingRule
+	for i := range options {
+		enc, err := gofind.LookupEncoding(options[i].Encoding)
+		if err != nil {
+			log.Print(err)
+			return nil, err
+		}
+
+		filter, err := fileNameFilterFromOptions(options[i].FileNames, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, encodingRule{encoding: enc, filter: filter})
+	}
+
+	return rules, nil
+}
+
+// checkOutputPaths returns an error if two files would be written to the same output file
+// Output files are the input files themselves, unless an output directory is set
+func checkOutputPaths(files []fileJob) error {
+	var err error
+	inputs := make(map[string]string)
+	for _, job := range files {
+		absPath, absErr := filepath.Abs(job.outputFilePath)
+		if absErr != nil {
+			absPath = job.outputFilePath
+		}
+		if input, ok := inputs[absPath]; ok {
+			err = fmt.Errorf("Files %s and %s would both be written to %s", input, job.path, job.outputFilePath)
+			log.Print(err)
+			continue
+		}
+		inputs[absPath] = job.path
+	}
+
+	return err
+}
+
+// encodingFor returns the encoding of the first rule matching the file, nil if none
+// relPath is the path of the file relative to the base of its input root, as tested by the file name filter
+func encodingFor(rules []encodingRule, path, relPath string) *gofind.Encoding {
+	for i := range rules {
+		if bPass, _, _ := rules[i].filter.TestPath(path, relPath, false); bPass {
+			return rules[i].encoding
+		}
+	}
+
+	return nil
+}
+
+// fileJob is a file to process
+type fileJob struct {
+	path string
+	// relPath is the path relative to the base of the input root of the file
+	relPath        string
+	outputFilePath string
+}
+
+func fileHandler(root inputRoot, ignorer *gofind.Ignorer, fnFilter *gofind.Filter, process func(job fileJob)) filepath.WalkFunc {
 	return func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return nil
 		}
 
-		bPass, _, excludeFile := fnFilter.TestFilters([]byte(path))
-		if excludeFile && info.IsDir() {
-			return filepath.SkipDir
+		fileName, err := relativePath(root.base, path)
+		if err != nil {
+			log.Print("Failed to find relative path for ", path, ", err=", err)
+			return err
 		}
 
-		if bPass && !info.IsDir() {
-			fileName, err := filepath.Rel(config.InputDirectory, path)
+		// Files ignored by the ignore files are skipped before the file name filter
+		if fileName != "." {
+			ignored, err := ignorer.Ignored(fileName, info.IsDir())
 			if err != nil {
-				log.Print("Failed to find relative path for ", path, ", err=", err)
+				log.Print("Error reading the ignore files for ", path, ", err=", err)
 				return err
 			}
-			outputFilePath := filepath.Join(config.OutputDirectory, fileName)
-
-			updated, _ := gofind.FileSearchReplace(path, outputFilePath, patterns, filter)
-			if updated {
-				*updatedFiles = append(*updatedFiles, path)
+			if ignored && info.IsDir() {
+				return filepath.SkipDir
+			}
+			if ignored {
+				return nil
 			}
 		}
 
+		var bPass, excludeFile bool
+		if fileName == "." {
+			// Globs do not apply on the input directory itself
+			bPass, _, excludeFile = fnFilter.TestFilters([]byte(path))
+		} else {
+			bPass, _, excludeFile = fnFilter.TestPath(path, fileName, info.IsDir())
+		}
+		if excludeFile && info.IsDir() {
+			return filepath.SkipDir
+		}
+
+		if bPass && !info.IsDir() {
+			outputFilePath := filepath.Join(root.outputBase, fileName)
+
+			process(fileJob{path: path, relPath: fileName, outputFilePath: outputFilePath})
+		}
+
 		return nil
 	}
 }
 
-func doFind() {
+// parallel runs process on numJobs goroutines
+// queue hands over a file to the next free goroutine; wait blocks until all the queued files are processed
+func parallel(numJobs int, process func(job fileJob)) (queue func(job fileJob), wait func()) {
+	jobs := make(chan fileJob)
+	var wg sync.WaitGroup
+	for i := 0; i < numJobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				process(j)
+			}
+		}()
+	}
+
+	queue = func(job fileJob) {
+		jobs <- job
+	}
+	wait = func() {
+		close(jobs)
+		wg.Wait()
+	}
+
+	return
+}
+
+// doPipe applies the patterns on the content read from r and writes the result to w
+// Content that does not pass the content filter is written unchanged
+func doPipe(r io.Reader, w io.Writer) int {
+	if len(config.Patterns) == 0 {
+		log.Print("No search/replace pattern")
+		return exitError
+	}
+
+	patterns := searchReplacePatternsFromOptions(config.Patterns)
+	if patterns == nil {
+		return exitError
+	}
+	filter, err := filterPatternsFromOptions(config.Filter)
+	if err != nil {
+		log.Print("Error compiling global filter patterns")
+		return exitError
+	}
+	lineEnding, err := gofind.ParseLineEnding(config.LineEndings)
+	if err != nil {
+		log.Print(err)
+		return exitError
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		log.Print("Error reading stdin, err=", err)
+		return exitError
+	}
+
+	if bPass, _, _ := filter.TestFilters(data); bPass {
+		if data, err = gofind.SearchReplaceLineEnding(data, patterns, lineEnding); err != nil {
+			log.Print(err)
+			return exitError
+		}
+	}
+
+	if _, err = w.Write(data); err != nil {
+		log.Print("Error writing stdout, err=", err)
+		return exitError
+	}
+
+	return exitOK
+}
+
+// doFind runs the search/replace on the input directory
+// Returns the list of files updated (or the files that would be updated in dry-run or check mode)
+func doFind() ([]string, error) {
 	var err error
 
 	// Compile the search text patterns
 	patterns := searchReplacePatternsFromOptions(config.Patterns)
+	if patterns == nil {
+		return nil, errors.New("Error compiling search/replace patterns")
+	}
 	filter, err := filterPatternsFromOptions(config.Filter)
 	if err != nil {
 		log.Print("Error compiling global filter patterns")
-		return
+		return nil, err
 	}
 
-	fnFilter, err := filterPatternsFromOptions(config.FileNames)
+	fnFilter, err := fileNameFilterFromOptions(config.FileNames, config.FileGlobs)
 	if err != nil {
 		log.Print("Error compiling file name filter patterns")
-		return
+		return nil, err
+	}
+
+	encodings, err := encodingRulesFromOptions(config.Encodings)
+	if err != nil {
+		log.Print("Error compiling encoding patterns")
+		return nil, err
+	}
+
+	lineEnding, err := gofind.ParseLineEnding(config.LineEndings)
+	if err != nil {
+		log.Print(err)
+		return nil, err
+	}
+
+	roots, err := inputRoots()
+	if err != nil {
+		log.Print("Error reading the input paths, err=", err)
+		return nil, err
+	}
+
+	// .gofindignore files are always honored, .gitignore files on request
+	ignoreFiles := []string{gofind.GoFindIgnoreFile}
+	var ignorePatterns []string
+	if config.GitIgnore {
+		ignoreFiles = []string{gofind.GitIgnoreFile, gofind.GoFindIgnoreFile}
+		ignorePatterns = []string{".git/"}
+	}
+	ignorers := make(map[string]*gofind.Ignorer)
+	for _, root := range roots {
+		if ignorers[root.base] != nil {
+			continue
+		}
+		ignorers[root.base], err = gofind.NewIgnorer(root.base, ignoreFiles, ignorePatterns...)
+		if err != nil {
+			log.Print("Error reading the ignore files, err=", err)
+			return nil, err
+		}
+	}
+
+	opts := gofind.FileOptions{
+		StreamThreshold:    streamSize,
+		MaxMatchSpan:       maxMatchSpan,
+		PreserveTimestamps: config.PreserveTimestamps,
+		Binary:             config.BinaryFiles,
+		LineEnding:         lineEnding,
+	}
+	if dryRun {
+		opts.DryRun = true
+		opts.Diff = gofind.NewSyncWriter(os.Stdout)
+	}
+	if checkOnly || grepMode {
+		opts.DryRun = true
+	}
+
+	var mu sync.Mutex
+	var updatedFiles, binaryFiles []string
+
+	var report *reporter
+	if len(reportFormat) > 0 {
+		report, err = newReporter(reportFormat, os.Stdout)
+		if err != nil {
+			log.Print(err)
+			return nil, err
+		}
+		defer report.close()
+	}
+	// The report of the files is also needed for the binary files skipped, the matches are only counted for the report
+	opts.SkipPatternStats = report == nil
+	opts.Report = func(result *gofind.FileResult) {
+		if result.Status == gofind.StatusBinary {
+			mu.Lock()
+			binaryFiles = append(binaryFiles, result.InputPath)
+			mu.Unlock()
+		}
+		if report != nil {
+			report.report(result)
+		}
+	}
+
+	if !opts.DryRun && !noJournal {
+		opts.Journal, err = gofind.NewJournal(journalDir)
+		if err != nil {
+			log.Print("Error creating the run journal, err=", err)
+			return nil, err
+		}
+		defer func() {
+			opts.Journal.Close()
+			if len(opts.Journal.Entries()) > 0 {
+				log.Printf("Run journal %s, use 'gofind undo %s' to restore the original files", opts.Journal.ID, opts.Journal.ID)
+			}
+		}()
+	}
+
+	numJobs := jobs
+	if numJobs <= 0 {
+		numJobs = runtime.NumCPU()
 	}
 
-	var updatedFiles []string
-	err = filepath.Walk(config.InputDirectory, fileHandler(patterns, &fnFilter, &filter, &updatedFiles))
+	var prompt *prompter
+	if interactive {
+		prompt = newPrompter(os.Stdin, os.Stderr)
+		opts.Confirm = prompt.confirm
+		// Questions have to be asked one at a time
+		numJobs = 1
+	}
+
+	process := func(job fileJob) {
+		fileOpts := opts
+		fileOpts.Encoding = encodingFor(encodings, job.path, job.relPath)
+		updated, _ := gofind.FileSearchReplaceWithOptions(job.path, job.outputFilePath, patterns, &filter, fileOpts)
+		if updated {
+			mu.Lock()
+			updatedFiles = append(updatedFiles, job.path)
+			mu.Unlock()
+		}
+	}
+
+	if grepMode {
+		before, after := contextBefore, contextAfter
+		if contextLines > 0 {
+			before, after = contextLines, contextLines
+		}
+		out := gofind.NewSyncWriter(os.Stdout)
+
+		process = func(job fileJob) {
+			path := job.path
+			fileOpts := opts
+			fileOpts.Encoding = encodingFor(encodings, path, job.relPath)
+			content, results, err := gofind.FileSearch(path, patterns, &filter, fileOpts)
+			if err != nil {
+				log.Printf("Error processing file %s. err=%v", path, err)
+				return
+			}
+			if len(results) == 0 {
+				return
+			}
+
+			// Write all the matches of the file in one go
+			var buf bytes.Buffer
+			writeMatches(&buf, path, content, results, before, after)
+			out.Write(buf.Bytes())
+
+			mu.Lock()
+			updatedFiles = append(updatedFiles, path)
+			mu.Unlock()
+		}
+	}
+
+	wait := func() {}
+	if numJobs > 1 {
+		process, wait = parallel(numJobs, process)
+	}
+
+	// The files are all listed before any is processed, to check their output paths
+	// A file reached from more than one input path is processed once
+	var files []fileJob
+	seen := make(map[string]bool)
+	collect := func(job fileJob) {
+		if absPath, err := filepath.Abs(job.path); err == nil {
+			if seen[absPath] {
+				return
+			}
+			seen[absPath] = true
+		}
+		files = append(files, job)
+	}
+
+	for _, root := range roots {
+		if err = filepath.Walk(root.path, fileHandler(root, ignorers[root.base], &fnFilter, collect)); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = checkOutputPaths(files)
+	}
+	if err == nil {
+		for _, job := range files {
+			if prompt != nil && prompt.quit {
+				break
+			}
+			process(job)
+		}
+	}
+	wait()
+
+	// Files are processed in parallel, keep the summary deterministic
+	sort.Strings(updatedFiles)
+	sort.Strings(binaryFiles)
 
 	updatedFileCount := len(updatedFiles)
-	if updatedFileCount > 0 {
+	if updatedFileCount > 0 || len(binaryFiles) > 0 {
 		log.Print("==== Summary ====")
-		log.Print(updatedFileCount, " file(s) updated:")
+	}
+	if updatedFileCount > 0 {
+		if grepMode {
+			log.Print(updatedFileCount, " file(s) with matches:")
+		} else if opts.DryRun {
+			log.Print(updatedFileCount, " file(s) would be updated:")
+		} else {
+			log.Print(updatedFileCount, " file(s) updated:")
+		}
 		for _, file := range updatedFiles {
 			log.Print(file)
 		}
 	}
+	if len(binaryFiles) > 0 {
+		log.Print(len(binaryFiles), " binary file(s) skipped:")
+		for _, file := range binaryFiles {
+			log.Print(file)
+		}
+	}
 
 	if err != nil {
 		log.Print(err)
 	}
+
+	return updatedFiles, err
 }
 
-func main() {
+// doUndo restores the files written by the run id, or by the last run if id is empty
+func doUndo(id string) int {
+	journal, err := gofind.OpenJournal(journalDir, id)
+	if err != nil {
+		log.Print("Error opening the run journal, err=", err)
+		return exitError
+	}
+
+	restored, err := journal.Undo()
+	for _, file := range restored {
+		log.Print(file, " - Restored")
+	}
+	if err != nil {
+		log.Print(err)
+		return exitError
+	}
+
+	log.Print("Run ", journal.ID, " undone, ", len(restored), " file(s) restored")
+	return exitOK
+}
+
+// run executes the command and returns the exit code
+func run() int {
 	if err := parseFlags(); err != nil {
-		return
+		// A configuration that cannot be loaded is the one problem validate finds
+		if d, ok := err.(diagnostic); ok && flag.NArg() > 0 && flag.Arg(0) == "validate" {
+			fmt.Fprintln(os.Stdout, d)
+		}
+		return exitError
 	}
 
 	if showVersion {
 		printVersion()
-		return
+		return exitOK
+	}
+
+	if flag.NArg() > 0 && flag.Arg(0) == "undo" {
+		return doUndo(flag.Arg(1))
+	}
+
+	if flag.NArg() > 0 && flag.Arg(0) == "schema" {
+		return doSchema(os.Stdout)
+	}
+
+	if flag.NArg() > 0 && flag.Arg(0) == "validate" {
+		return doValidate(os.Stdout)
+	}
+
+	if flag.NArg() == 1 && flag.Arg(0) == "-" {
+		if !checkConfig(false) {
+			return exitError
+		}
+		return doPipe(os.Stdin, os.Stdout)
 	}
 
 	if len(generateConfigFileName) > 0 {
-		if err := ioutil.WriteFile(generateConfigFileName, templateConfigData, 0777); err != nil {
+		data, err := templateConfig(generateConfigFileName)
+		if err != nil {
+			log.Print("Error generating ", generateConfigFileName, ", err=", err)
+			return exitError
+		}
+		if err := ioutil.WriteFile(generateConfigFileName, data, 0777); err != nil {
 			log.Print("Error writing", generateConfigFileName, ", err=", err)
+			return exitError
 		}
-		return
+		return exitOK
 	}
 
 	if err := validateFlags(); err != nil {
 		log.Print(err)
 		printUsage()
+		return exitError
+	}
+
+	if !checkConfig(true) {
+		return exitError
 	}
 
 	log.Printf("Starting")
 	defer log.Printf("Ending")
 
-	doFind()
+	updatedFiles, err := doFind()
+	if err != nil {
+		return exitError
+	}
+
+	if checkOnly && len(updatedFiles) > 0 {
+		for _, file := range updatedFiles {
+			fmt.Println(file)
+		}
+		log.Print("Check failed: ", len(updatedFiles), " file(s) need to be updated")
+		return exitCheckFailed
+	}
+
+	return exitOK
+}
+
+func main() {
+	os.Exit(run())
 }
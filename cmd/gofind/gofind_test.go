@@ -7,11 +7,26 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/prijip/gofind"
 	"github.com/stretchr/testify/assert"
 )
 
+func TestMain(m *testing.M) {
+	// Keep the run journals out of the user cache
+	dir, err := ioutil.TempDir("", "gofind-journal")
+	if err != nil {
+		panic(err)
+	}
+	flag.Set("journal-dir", dir)
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
 func TestParseFlags(t *testing.T) {
 	err := flag.Set("config", "testdata/config.json")
 	assert.NoError(t, err)
@@ -125,7 +140,7 @@ func TestGenerateConfigGoResource(t *testing.T) {
 	inFileData, err := ioutil.ReadFile(sourceFileName)
 	assert.NoError(t, err)
 
-	_, err = outFile.Write([]byte("package main\n"))
+	_, err = outFile.Write([]byte("package main\n\n"))
 	assert.NoError(t, err)
 
 	_, err = outFile.Write([]byte("// Generated content\n"))
@@ -140,3 +155,437 @@ func TestGenerateConfigGoResource(t *testing.T) {
 	_, err = outFile.Write([]byte("`)\n"))
 	assert.NoError(t, err)
 }
+
+func TestSearchReplace_DryRun(t *testing.T) {
+	err := flag.Set("config", "testdata/config.yaml")
+	assert.NoError(t, err)
+
+	err = flag.Set("dry-run", "true")
+	assert.NoError(t, err)
+	defer flag.Set("dry-run", "false")
+
+	err = parseFlags()
+	assert.NoError(t, err)
+
+	outDir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(outDir)
+	config.OutputDirectory = outDir
+
+	doFind()
+
+	actualFiles, err := getFileList(outDir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"."}, actualFiles)
+}
+
+func TestRun_Check(t *testing.T) {
+	outDir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(outDir)
+
+	assert.NoError(t, flag.Set("config", "testdata/config.yaml"))
+	assert.NoError(t, flag.Set("out-dir", outDir))
+	defer flag.Set("out-dir", "")
+	assert.NoError(t, flag.Set("check", "true"))
+	defer flag.Set("check", "false")
+
+	assert.Equal(t, exitCheckFailed, run())
+
+	actualFiles, err := getFileList(outDir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"."}, actualFiles)
+}
+
+func TestRun_CheckNoChange(t *testing.T) {
+	assert.NoError(t, flag.Set("config", "testdata/config.yaml"))
+	assert.NoError(t, flag.Set("in-dir", "testdata/expected_output"))
+	defer flag.Set("in-dir", "")
+	assert.NoError(t, flag.Set("check", "true"))
+	defer flag.Set("check", "false")
+
+	assert.Equal(t, exitOK, run())
+}
+
+func TestRun_ReportCheck(t *testing.T) {
+	assert.NoError(t, flag.Set("config", "testdata/config.yaml"))
+	assert.NoError(t, flag.Set("report", "json"))
+	defer flag.Set("report", "")
+
+	for _, name := range []string{"check", "dry-run", "grep"} {
+		assert.NoError(t, flag.Set(name, "true"))
+		assert.Equal(t, exitError, run(), name)
+		assert.NoError(t, flag.Set(name, "false"))
+	}
+}
+
+func TestValidateFlags_InteractiveFilesFromStdin(t *testing.T) {
+	assert.NoError(t, flag.Set("config", "testdata/config.yaml"))
+	assert.NoError(t, parseFlags())
+	defer func() { interactive, filesFrom = false, "" }()
+
+	interactive, filesFrom = true, "-"
+	assert.Error(t, validateFlags())
+
+	filesFrom = "files.txt"
+	assert.NoError(t, validateFlags())
+}
+
+func TestSearchReplace_Jobs(t *testing.T) {
+	outDir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(outDir)
+
+	assert.NoError(t, flag.Set("config", "testdata/config.yaml"))
+	assert.NoError(t, flag.Set("out-dir", outDir))
+	defer flag.Set("out-dir", "")
+	assert.NoError(t, flag.Set("jobs", "4"))
+	defer flag.Set("jobs", "1")
+
+	err = parseFlags()
+	assert.NoError(t, err)
+
+	updatedFiles, err := doFind()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"testdata/input/f1.in",
+		"testdata/input/f2.in",
+		"testdata/input/f3.in",
+		"testdata/input/subdir/f1.in",
+		"testdata/input/subdir/f2.in",
+		"testdata/input/subdir/f3.in",
+	}, updatedFiles)
+
+	expectedFiles, err := getFileList("./testdata/expected_output")
+	assert.NoError(t, err)
+
+	actualFiles, err := getFileList(outDir)
+	assert.NoError(t, err)
+
+	assert.ElementsMatch(t, expectedFiles, actualFiles)
+
+	for _, fileName := range actualFiles {
+		assertFilesEqualIgnoreNL(t, filepath.Join("./testdata/expected_output", fileName), filepath.Join(outDir, fileName))
+	}
+}
+
+func TestRun_Undo(t *testing.T) {
+	outDir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(outDir)
+
+	// Use a journal directory of its own, to undo this run only
+	journalDir, err := ioutil.TempDir("", "gofind-journal")
+	assert.NoError(t, err)
+	defer os.RemoveAll(journalDir)
+	defer flag.Set("journal-dir", flag.Lookup("journal-dir").Value.String())
+	assert.NoError(t, flag.Set("journal-dir", journalDir))
+
+	// Existing output file, to be restored
+	err = ioutil.WriteFile(filepath.Join(outDir, "f1.in"), []byte("original"), 0644)
+	assert.NoError(t, err)
+
+	assert.NoError(t, flag.Set("config", "testdata/config.yaml"))
+	assert.NoError(t, flag.Set("out-dir", outDir))
+	defer flag.Set("out-dir", "")
+
+	assert.Equal(t, exitOK, run())
+
+	actualFiles, err := getFileList(outDir)
+	assert.NoError(t, err)
+	assert.Len(t, actualFiles, 8)
+
+	err = flag.CommandLine.Parse([]string{"undo"})
+	assert.NoError(t, err)
+	defer flag.CommandLine.Parse([]string{})
+
+	assert.Equal(t, exitOK, doUndo(flag.Arg(1)))
+
+	actualFiles, err = getFileList(outDir)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{".", "f1.in", "subdir"}, actualFiles)
+
+	content, err := ioutil.ReadFile(filepath.Join(outDir, "f1.in"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("original"), content)
+
+	// Nothing left to undo
+	assert.Equal(t, exitError, doUndo(""))
+}
+
+func TestSearchReplace_FileGlobs(t *testing.T) {
+	outDir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(outDir)
+
+	assert.NoError(t, flag.Set("config", "testdata/config.yaml"))
+	err = parseFlags()
+	assert.NoError(t, err)
+
+	config.OutputDirectory = outDir
+	config.FileNames = FilterOptions{Exclude: []string{"glob:e*"}}
+	config.FileGlobs = []string{"*.in", "!subdir/"}
+
+	updatedFiles, err := doFind()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"testdata/input/f1.in",
+		"testdata/input/f2.in",
+		"testdata/input/f3.in",
+	}, updatedFiles)
+}
+
+func TestSearchReplace_IgnoreFiles(t *testing.T) {
+	inDir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(inDir)
+
+	files := map[string]string{
+		".gitignore":    "ignored.in\nsub/\n",
+		".gofindignore": "skipped.in\n",
+		"a.in":          "one",
+		"ignored.in":    "one",
+		"skipped.in":    "one",
+		"sub/b.in":      "one",
+	}
+	for name, content := range files {
+		path := filepath.Join(inDir, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), os.ModePerm))
+		assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+
+	assert.NoError(t, flag.Set("config", "testdata/config.json"))
+	assert.NoError(t, flag.Set("in-dir", inDir))
+	defer flag.Set("in-dir", "")
+	assert.NoError(t, flag.Set("search", "one"))
+	defer flag.Set("search", "")
+	assert.NoError(t, flag.Set("replace", "ONE"))
+	assert.NoError(t, flag.Set("dry-run", "true"))
+	defer flag.Set("dry-run", "false")
+
+	err = parseFlags()
+	assert.NoError(t, err)
+
+	updatedFiles, err := doFind()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(inDir, "a.in"),
+		filepath.Join(inDir, "ignored.in"),
+		filepath.Join(inDir, "sub", "b.in"),
+	}, updatedFiles)
+
+	config.GitIgnore = true
+	updatedFiles, err = doFind()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(inDir, "a.in")}, updatedFiles)
+}
+
+func TestSearchReplace_Encodings(t *testing.T) {
+	inDir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(inDir)
+	outDir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(outDir)
+
+	files := map[string][]byte{
+		"a.txt":  []byte("caf\xe9"),
+		"b.rc":   []byte{'c', 0, 0xe9, 0},
+		"c.utf8": []byte("\xef\xbb\xbfcafé"),
+	}
+	for name, content := range files {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(inDir, name), content, 0644))
+	}
+
+	assert.NoError(t, flag.Set("config", "testdata/config.json"))
+	assert.NoError(t, flag.Set("in-dir", inDir))
+	defer flag.Set("in-dir", "")
+	assert.NoError(t, flag.Set("search", "é"))
+	defer flag.Set("search", "")
+	assert.NoError(t, flag.Set("replace", "e"))
+
+	err = parseFlags()
+	assert.NoError(t, err)
+
+	config.OutputDirectory = outDir
+	config.FileNames = FilterOptions{}
+	config.Encodings = []EncodingOption{
+		{Encoding: "utf-16le", FileNames: FilterOptions{Include: []string{"glob:*.rc"}}},
+		{Encoding: "latin1", FileNames: FilterOptions{Include: []string{`\.txt$`}}},
+	}
+
+	updatedFiles, err := doFind()
+	assert.NoError(t, err)
+	assert.Len(t, updatedFiles, 3)
+
+	expected := map[string][]byte{
+		"a.txt":  []byte("cafe"),
+		"b.rc":   []byte{'c', 0, 'e', 0},
+		"c.utf8": []byte("\xef\xbb\xbfcafe"),
+	}
+	for name, content := range expected {
+		data, err := ioutil.ReadFile(filepath.Join(outDir, name))
+		assert.NoError(t, err)
+		assert.Equal(t, content, data, name)
+	}
+
+	config.Encodings = []EncodingOption{{Encoding: "ebcdic"}}
+	_, err = doFind()
+	assert.Error(t, err)
+}
+
+func TestSearchReplace_LineEndings(t *testing.T) {
+	inDir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(inDir)
+
+	inFilePath := filepath.Join(inDir, "f.in")
+	assert.NoError(t, ioutil.WriteFile(inFilePath, []byte("one\r\ntwo\r\n"), 0644))
+
+	assert.NoError(t, flag.Set("config", "testdata/config.json"))
+	assert.NoError(t, flag.Set("in-dir", inDir))
+	defer flag.Set("in-dir", "")
+	assert.NoError(t, flag.Set("line-endings", "lf"))
+	defer flag.Set("line-endings", "")
+
+	err = parseFlags()
+	assert.NoError(t, err)
+	assert.Equal(t, "lf", config.LineEndings)
+
+	config.OutputDirectory = inDir
+
+	updatedFiles, err := doFind()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{inFilePath}, updatedFiles)
+
+	data, err := ioutil.ReadFile(inFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "one\nTWO\n", string(data))
+
+	config.LineEndings = "cr"
+	_, err = doFind()
+	assert.Error(t, err)
+}
+
+func TestSearchRegexFromOption(t *testing.T) {
+	tests := []struct {
+		option  SearchReplaceOption
+		data    string
+		matches []string
+	}{
+		{SearchReplaceOption{Search: "a.b(c)"}, "a.bc axbc", []string{"a.bc", "axbc"}},
+		{SearchReplaceOption{Search: "a.b(c)", Literal: true}, "a.b(c) axb(c)", []string{"a.b(c)"}},
+		{SearchReplaceOption{Search: "one", IgnoreCase: true}, "One ONE", []string{"One", "ONE"}},
+		{SearchReplaceOption{Search: "one", PreserveCase: true}, "One ONE", []string{"One", "ONE"}},
+		{SearchReplaceOption{Search: "one|two", WholeWord: true}, "one ones two", []string{"one", "two"}},
+		{SearchReplaceOption{Search: "price($)", Literal: true, WholeWord: true, IgnoreCase: true}, "Price($)x UnitPrice($)", []string{"Price($)"}},
+	}
+
+	for _, test := range tests {
+		re, err := searchRegexFromOption(&test.option)
+		assert.NoError(t, err)
+		assert.Equal(t, test.matches, re.FindAllString(test.data, -1), test.option.Search)
+	}
+}
+
+func TestSearchReplace_Literal(t *testing.T) {
+	assert.NoError(t, flag.Set("config", "testdata/config.json"))
+	assert.NoError(t, flag.Set("search", "(two)"))
+	defer flag.Set("search", "")
+	assert.NoError(t, flag.Set("replace", "$1$"))
+	assert.NoError(t, flag.Set("literal", "true"))
+	defer flag.Set("literal", "false")
+	assert.NoError(t, flag.Set("replace-literal", "true"))
+	defer flag.Set("replace-literal", "false")
+
+	err := parseFlags()
+	assert.NoError(t, err)
+
+	patterns := searchReplacePatternsFromOptions(config.Patterns)
+	assert.Len(t, patterns, 2)
+	replaced, err := gofind.SearchReplace([]byte("two (two)"), patterns[1:])
+	assert.NoError(t, err)
+	assert.Equal(t, "two $1$", string(replaced))
+}
+
+func TestDoPipe(t *testing.T) {
+	assert.NoError(t, flag.Set("config", "testdata/config.json"))
+	err := parseFlags()
+	assert.NoError(t, err)
+
+	var out bytes.Buffer
+	assert.Equal(t, exitOK, doPipe(strings.NewReader("one two\n"), &out))
+	assert.Equal(t, "one TWO\n", out.String())
+
+	// Content not passing the filter is written unchanged
+	config.Filter = FilterOptions{Exclude: []string{"^one"}}
+	out.Reset()
+	assert.Equal(t, exitOK, doPipe(strings.NewReader("one two\n"), &out))
+	assert.Equal(t, "one two\n", out.String())
+
+	config.Patterns = nil
+	assert.Equal(t, exitError, doPipe(strings.NewReader("one two\n"), &out))
+}
+
+func TestDoPipe_LineEndings(t *testing.T) {
+	assert.NoError(t, flag.Set("config", "testdata/config.json"))
+	err := parseFlags()
+	assert.NoError(t, err)
+
+	config.Filter = FilterOptions{}
+	config.Patterns = []SearchReplaceOption{{Search: "b", Replace: StringOption{valid: true, value: "X\nY"}}}
+
+	// The replacement takes the line ending of the input
+	var out bytes.Buffer
+	assert.Equal(t, exitOK, doPipe(strings.NewReader("hello b world\r\n"), &out))
+	assert.Equal(t, "hello X\r\nY world\r\n", out.String())
+
+	// and the output is normalized if asked
+	config.LineEndings = "lf"
+	out.Reset()
+	assert.Equal(t, exitOK, doPipe(strings.NewReader("hello b world\r\n"), &out))
+	assert.Equal(t, "hello X\nY world\n", out.String())
+}
+
+func TestDoPipe_Counter(t *testing.T) {
+	assert.NoError(t, flag.Set("config", "testdata/config.json"))
+	err := parseFlags()
+	assert.NoError(t, err)
+
+	config.Counter = CounterOption{Start: 10, Step: 10, Width: 3}
+	config.Patterns = []SearchReplaceOption{
+		{Search: `case\d*`, Replace: StringOption{valid: true, value: "case${#counter}"}},
+		{Search: `\[\d*\]`, ReplaceTemplate: "[{{.Index}}]"},
+	}
+
+	var out bytes.Buffer
+	assert.Equal(t, exitOK, doPipe(strings.NewReader("case7 [] case [9]"), &out))
+	assert.Equal(t, "case010 [3] case020 [4]", out.String())
+}
+
+func TestEncodingFor(t *testing.T) {
+	rules, err := encodingRulesFromOptions([]EncodingOption{
+		{Encoding: "utf-16le", FileNames: FilterOptions{Include: []string{"glob:sub/*.rc"}}},
+	})
+	assert.NoError(t, err)
+
+	// Globs match the path relative to the input root, as the file name filter does
+	path := filepath.Join("other", "root", "sub", "b.rc")
+	assert.Equal(t, gofind.UTF16LE, encodingFor(rules, path, filepath.Join("sub", "b.rc")))
+	assert.Nil(t, encodingFor(rules, path, filepath.Join("root", "sub", "b.rc")))
+}
+
+func TestDoPipe_ReplaceLiteralVars(t *testing.T) {
+	assert.NoError(t, flag.Set("config", "testdata/config.json"))
+	err := parseFlags()
+	assert.NoError(t, err)
+
+	// The variables are expanded in a literal replacement, $1 is not
+	config.Patterns = []SearchReplaceOption{
+		{Search: `price\(\$\)`, Replace: StringOption{valid: true, value: "cost($) $1 #${#index}"}, ReplaceLiteral: true},
+	}
+
+	var out bytes.Buffer
+	assert.Equal(t, exitOK, doPipe(strings.NewReader("price($) price($)"), &out))
+	assert.Equal(t, "cost($) $1 #1 cost($) $1 #2", out.String())
+}
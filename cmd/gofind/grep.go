@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/prijip/gofind"
+)
+
+// writeMatches writes the matches found in a file, one line per match, as
+//
+//	path:line:column: text
+//
+// which editors can load as a quickfix list
+// Context lines are written as
+//
+//	path-line-text
+//
+// with "--" between the groups of context lines that are not adjacent
+func writeMatches(w io.Writer, path string, data []byte, results []gofind.SearchResult, before, after int) {
+	if len(results) == 0 {
+		return
+	}
+
+	// Start offset of each line
+	lineStarts := []int{0}
+	for i, b := range data {
+		if b == '\n' && i+1 < len(data) {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+
+	lineText := func(line int) []byte {
+		end := len(data)
+		if line < len(lineStarts) {
+			end = lineStarts[line]
+		}
+		text := data[lineStarts[line-1]:end]
+		text = bytes.TrimSuffix(text, []byte{'\n'})
+		return bytes.TrimSuffix(text, []byte{'\r'})
+	}
+
+	// Lines to be written, along with the matches on them
+	matches := map[int][]gofind.SearchResult{}
+	show := map[int]bool{}
+	for _, r := range results {
+		matches[r.Line] = append(matches[r.Line], r)
+		for line := r.Line - before; line <= r.Line+after; line++ {
+			if line >= 1 && line <= len(lineStarts) {
+				show[line] = true
+			}
+		}
+	}
+
+	var lines []int
+	for line := range show {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+
+	for i, line := range lines {
+		if i > 0 && line != lines[i-1]+1 && before+after > 0 {
+			fmt.Fprintln(w, "--")
+		}
+
+		if len(matches[line]) == 0 {
+			fmt.Fprintf(w, "%s-%d-%s\n", path, line, lineText(line))
+			continue
+		}
+		for _, r := range matches[line] {
+			fmt.Fprintf(w, "%s:%d:%d: %s\n", path, line, r.Column, lineText(line))
+		}
+	}
+}
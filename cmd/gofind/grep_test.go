@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/prijip/gofind"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteMatches(t *testing.T) {
+	data := []byte("1\n2 one\n3\n4\n5\n6\n7 one one\n8\r\n9\n")
+	results := []gofind.SearchResult{
+		{Line: 2, Column: 3},
+		{Line: 7, Column: 3},
+		{Line: 7, Column: 7},
+	}
+
+	var out bytes.Buffer
+	writeMatches(&out, "f.in", data, results, 0, 0)
+	assert.Equal(t, `f.in:2:3: 2 one
+f.in:7:3: 7 one one
+f.in:7:7: 7 one one
+`, out.String())
+
+	out.Reset()
+	writeMatches(&out, "f.in", data, results, 1, 2)
+	assert.Equal(t, `f.in-1-1
+f.in:2:3: 2 one
+f.in-3-3
+f.in-4-4
+--
+f.in-6-6
+f.in:7:3: 7 one one
+f.in:7:7: 7 one one
+f.in-8-8
+f.in-9-9
+`, out.String())
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// inputRoot is a directory to search, or a file to process
+type inputRoot struct {
+	// path of the directory or the file
+	path string
+	// base is the directory the paths tested by the globs and the ignore files are relative to
+	base string
+	// outputBase is the directory of the output that base maps to
+	outputBase string
+}
+
+// readInputPaths returns the paths given as arguments, followed by the paths listed in the
+// file filesFrom ("-" reads stdin). The list is NUL delimited if it has a NUL, newline delimited otherwise
+func readInputPaths(args []string, filesFrom string, stdin io.Reader) ([]string, error) {
+	paths := append([]string(nil), args...)
+	if len(filesFrom) == 0 {
+		return paths, nil
+	}
+
+	var data []byte
+	var err error
+	if filesFrom == "-" {
+		data, err = ioutil.ReadAll(stdin)
+	} else {
+		data, err = ioutil.ReadFile(filesFrom)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sep := []byte{'\n'}
+	if bytes.IndexByte(data, 0) >= 0 {
+		sep = []byte{0}
+	}
+	for _, entry := range bytes.Split(data, sep) {
+		if path := strings.TrimRight(string(entry), "\r"); len(path) > 0 {
+			paths = append(paths, path)
+		}
+	}
+
+	return paths, nil
+}
+
+// inputRoots returns the directories and files to process: the input paths if
+// any were given, the input directories of the configuration otherwise
+func inputRoots() ([]inputRoot, error) {
+	paths := inputPaths
+	if len(paths) == 0 {
+		if len(config.InputDirectory) > 0 {
+			paths = append(paths, config.InputDirectory)
+		}
+		paths = append(paths, config.InputDirectories...)
+	}
+
+	var roots []inputRoot
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, newInputRoot(path, info.IsDir()))
+	}
+
+	return roots, nil
+}
+
+// newInputRoot maps the path into the output directory
+// Paths inside the input directory keep their location relative to it. Paths inside
+// one of the other input directories are placed in the output directory under the
+// name of that directory, as when it is walked. Other directories are placed in the
+// output directory under their own name, and other files directly in the output
+// directory. Files mapped to the same output file are rejected by checkOutputPaths
+// before any file is processed
+func newInputRoot(path string, isDir bool) inputRoot {
+	root := inputRoot{
+		path:       path,
+		base:       config.InputDirectory,
+		outputBase: config.OutputDirectory,
+	}
+
+	if !isWithin(config.InputDirectory, path) {
+		dir := ""
+		for _, inputDir := range config.InputDirectories {
+			if isWithin(inputDir, path) {
+				dir = inputDir
+				break
+			}
+		}
+		if len(dir) == 0 && isDir {
+			dir = path
+		}
+
+		if len(dir) > 0 {
+			root.base = dir
+			if absDir, err := filepath.Abs(dir); err == nil {
+				root.outputBase = filepath.Join(config.OutputDirectory, filepath.Base(absDir))
+			}
+		} else {
+			root.base = filepath.Dir(path)
+		}
+	}
+
+	// Files are updated in place, unless the output directory is set apart from the input directory
+	if len(config.OutputDirectory) == 0 || config.OutputDirectory == config.InputDirectory {
+		root.outputBase = root.base
+	}
+
+	return root
+}
+
+// relativePath returns the path relative to base, even if only one of them is absolute
+func relativePath(base, path string) (string, error) {
+	rel, err := filepath.Rel(base, path)
+	if err == nil {
+		return rel, nil
+	}
+
+	absBase, err := filepath.Abs(base)
+	if err != nil {
+		return "", err
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Rel(absBase, absPath)
+}
+
+// isWithin reports whether path is dir or is inside dir
+func isWithin(dir, path string) bool {
+	if len(dir) == 0 {
+		return false
+	}
+
+	rel, err := relativePath(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadInputPaths(t *testing.T) {
+	paths, err := readInputPaths([]string{"a"}, "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, paths)
+
+	paths, err = readInputPaths([]string{"a"}, "-", strings.NewReader("b\r\nc d\n\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c d"}, paths)
+
+	paths, err = readInputPaths(nil, "-", strings.NewReader("b\nc\x00d\x00"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b\nc", "d"}, paths)
+
+	_, err = readInputPaths(nil, "testdata/missing", nil)
+	assert.Error(t, err)
+}
+
+func TestNewInputRoot(t *testing.T) {
+	defer func() { config = AppConfig{} }()
+
+	config = AppConfig{InputDirectory: "in", OutputDirectory: "out"}
+	assert.Equal(t, inputRoot{"in/sub", "in", "out"}, newInputRoot("in/sub", true))
+	assert.Equal(t, inputRoot{"in/f", "in", "out"}, newInputRoot("in/f", false))
+	assert.Equal(t, inputRoot{"other/sub", "other/sub", "out/sub"}, newInputRoot("other/sub", true))
+	assert.Equal(t, inputRoot{"other/f", "other", "out"}, newInputRoot("other/f", false))
+
+	// Paths inside the other input directories are mapped as when the directory is walked
+	config.InputDirectories = []string{"r2"}
+	assert.Equal(t, inputRoot{"r2", "r2", "out/r2"}, newInputRoot("r2", true))
+	assert.Equal(t, inputRoot{"r2/b.txt", "r2", "out/r2"}, newInputRoot("r2/b.txt", false))
+	assert.Equal(t, inputRoot{"r2/sub", "r2", "out/r2"}, newInputRoot("r2/sub", true))
+
+	// In place
+	config = AppConfig{InputDirectory: "in", OutputDirectory: "in"}
+	assert.Equal(t, inputRoot{"other/sub", "other/sub", "other/sub"}, newInputRoot("other/sub", true))
+	config = AppConfig{}
+	assert.Equal(t, inputRoot{"other/f", "other", "other"}, newInputRoot("other/f", false))
+}
+
+func TestSearchReplace_InputPaths(t *testing.T) {
+	outDir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(outDir)
+	otherDir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(otherDir)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(otherDir, "g.in"), []byte("one"), 0644))
+
+	assert.NoError(t, flag.Set("config", "testdata/config.yaml"))
+	err = parseFlags()
+	assert.NoError(t, err)
+	defer func() { inputPaths = nil }()
+
+	config.OutputDirectory = outDir
+	config.FileNames = FilterOptions{}
+	inputPaths = []string{
+		"testdata/input/subdir",
+		"testdata/input/f1.in",
+		otherDir,
+		"testdata/input/subdir/f1.in",
+	}
+
+	updatedFiles, err := doFind()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(otherDir, "g.in"),
+		"testdata/input/f1.in",
+		"testdata/input/subdir/e1.ex",
+		"testdata/input/subdir/e2.ex",
+		"testdata/input/subdir/f1.in",
+		"testdata/input/subdir/f2.in",
+		"testdata/input/subdir/f3.in",
+	}, updatedFiles)
+
+	for _, name := range []string{"f1.in", "subdir/f1.in", "subdir/e1.ex", filepath.Join(filepath.Base(otherDir), "g.in")} {
+		_, err := os.Stat(filepath.Join(outDir, name))
+		assert.NoError(t, err, name)
+	}
+}
+
+func TestSearchReplace_InputPathsSameOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"a/x.in", "b/x.in"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), os.ModePerm))
+		assert.NoError(t, ioutil.WriteFile(path, []byte("one"), 0644))
+	}
+	outDir := filepath.Join(dir, "out")
+
+	assert.NoError(t, flag.Set("config", "testdata/config.yaml"))
+	err = parseFlags()
+	assert.NoError(t, err)
+	defer func() { inputPaths = nil }()
+
+	config.OutputDirectory = outDir
+	inputPaths = []string{filepath.Join(dir, "a", "x.in"), filepath.Join(dir, "b", "x.in")}
+
+	// Both files would be written to out/x.in, none is processed
+	_, err = doFind()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), filepath.Join(outDir, "x.in"))
+	}
+	_, err = os.Stat(outDir)
+	assert.True(t, os.IsNotExist(err))
+}
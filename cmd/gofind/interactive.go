@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/prijip/gofind"
+)
+
+// interactiveContextLines is the number of lines shown around a match
+const interactiveContextLines = 2
+
+// prompter asks the user to confirm each match
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	// quit is set once the user chooses to stop processing
+	quit bool
+}
+
+func newPrompter(in io.Reader, out io.Writer) *prompter {
+	return &prompter{
+		in:  bufio.NewReader(in),
+		out: out,
+	}
+}
+
+// confirm shows the match with the surrounding lines and reads the decision
+func (p *prompter) confirm(m *gofind.Match) gofind.Decision {
+	if p.quit {
+		return gofind.ReplaceQuit
+	}
+
+	p.showMatch(m)
+
+	for {
+		fmt.Fprint(p.out, "Replace? [y]es, [n]o, [a]ll in file, [q]uit: ")
+
+		answer, err := p.in.ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			return gofind.ReplaceYes
+		case "n", "no":
+			return gofind.ReplaceNo
+		case "a", "all":
+			return gofind.ReplaceAll
+		case "q", "quit":
+			p.quit = true
+			return gofind.ReplaceQuit
+		}
+
+		// No more input, leave everything else as it is
+		if err != nil {
+			fmt.Fprintln(p.out)
+			p.quit = true
+			return gofind.ReplaceQuit
+		}
+	}
+}
+
+// showMatch prints the lines containing the match, before and after replacement
+func (p *prompter) showMatch(m *gofind.Match) {
+	// Find the lines containing the match
+	lineStart := bytes.LastIndexByte(m.Data[:m.Start], '\n') + 1
+	lineEnd := len(m.Data)
+	if i := bytes.IndexByte(m.Data[m.End:], '\n'); i >= 0 {
+		lineEnd = m.End + i
+	}
+	lineNum := bytes.Count(m.Data[:lineStart], []byte{'\n'}) + 1
+
+	fmt.Fprintf(p.out, "%s:%d\n", m.Path, lineNum)
+
+	// Context before the match
+	before := lineStart
+	for i := 0; i < interactiveContextLines && before > 0; i++ {
+		before = bytes.LastIndexByte(m.Data[:before-1], '\n') + 1
+	}
+	p.showLines(" ", lineNum-bytes.Count(m.Data[before:lineStart], []byte{'\n'}), m.Data[before:lineStart])
+
+	p.showLines("-", lineNum, m.Data[lineStart:lineEnd])
+
+	var replaced []byte
+	replaced = append(replaced, m.Data[lineStart:m.Start]...)
+	replaced = append(replaced, m.Replacement...)
+	replaced = append(replaced, m.Data[m.End:lineEnd]...)
+	p.showLines("+", lineNum, replaced)
+
+	// Context after the match
+	if lineEnd < len(m.Data) {
+		after := lineEnd + 1
+		for i := 0; i < interactiveContextLines && after < len(m.Data); i++ {
+			if j := bytes.IndexByte(m.Data[after:], '\n'); j >= 0 {
+				after += j + 1
+			} else {
+				after = len(m.Data)
+			}
+		}
+		p.showLines(" ", lineNum+bytes.Count(m.Data[lineStart:lineEnd], []byte{'\n'})+1, m.Data[lineEnd+1:after])
+	}
+}
+
+// showLines prints the lines in data with a prefix and line numbers starting from lineNum
+func (p *prompter) showLines(prefix string, lineNum int, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		fmt.Fprintf(p.out, "%s%5d | %s\n", prefix, lineNum, strings.TrimSuffix(line, "\r"))
+		lineNum++
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/prijip/gofind"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrompter_Confirm(t *testing.T) {
+	data := []byte("1\n2\n3 one\n4\n5\n6\n")
+	m := &gofind.Match{
+		Path:        "f.in",
+		Data:        data,
+		Start:       6,
+		End:         9,
+		Replacement: []byte("ONE"),
+	}
+
+	var out bytes.Buffer
+	p := newPrompter(strings.NewReader("x\ny\nn\na\nq\n"), &out)
+
+	assert.Equal(t, gofind.ReplaceYes, p.confirm(m))
+	assert.Equal(t, gofind.ReplaceNo, p.confirm(m))
+	assert.Equal(t, gofind.ReplaceAll, p.confirm(m))
+	assert.Equal(t, gofind.ReplaceQuit, p.confirm(m))
+	assert.True(t, p.quit)
+
+	expectedOutput := `f.in:3
+     1 | 1
+     2 | 2
+-    3 | 3 one
++    3 | 3 ONE
+     4 | 4
+     5 | 5
+`
+	assert.True(t, strings.HasPrefix(out.String(), expectedOutput), out.String())
+}
+
+func TestPrompter_ConfirmEOF(t *testing.T) {
+	var out bytes.Buffer
+	p := newPrompter(strings.NewReader(""), &out)
+
+	m := &gofind.Match{Data: []byte("one"), Start: 0, End: 3, Replacement: []byte("ONE")}
+	assert.Equal(t, gofind.ReplaceQuit, p.confirm(m))
+	assert.True(t, p.quit)
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
+	"strconv"
 )
 
 // StringOption represents an optional string
@@ -49,3 +51,33 @@ func (opt *StringOption) UnmarshalJSON(b []byte) error {
 func (opt *StringOption) IsValid() bool {
 	return opt.valid
 }
+
+// ScalarOption is a setting read as text, which may also be written as a number or a
+// boolean, e.g. occurrences: 2 or year: 2019
+type ScalarOption string
+
+// UnmarshalJSON reads a string, a number or a boolean, null leaves the option empty
+func (opt *ScalarOption) UnmarshalJSON(b []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case nil:
+		*opt = ""
+	case string:
+		*opt = ScalarOption(v)
+	case json.Number:
+		*opt = ScalarOption(v.String())
+	case bool:
+		*opt = ScalarOption(strconv.FormatBool(v))
+	default:
+		return fmt.Errorf("Expected a string, a number or a boolean, got %s", b)
+	}
+
+	return nil
+}
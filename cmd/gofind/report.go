@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/prijip/gofind"
+)
+
+// Report formats
+const (
+	reportJSON   = "json"
+	reportNDJSON = "ndjson"
+)
+
+// jsonReport is the document written in the json report format
+type jsonReport struct {
+	Files []*gofind.FileResult `json:"files"`
+}
+
+// reporter writes the outcome of processing each file in a machine readable format
+type reporter struct {
+	format string
+	w      io.Writer
+
+	mu      sync.Mutex
+	results []*gofind.FileResult
+}
+
+func newReporter(format string, w io.Writer) (*reporter, error) {
+	switch format {
+	case reportJSON, reportNDJSON:
+	default:
+		return nil, fmt.Errorf("Unknown report format '%s'", format)
+	}
+
+	return &reporter{format: format, w: w}, nil
+}
+
+// report records the outcome of a file
+// In the ndjson format, it is written right away as one line
+func (r *reporter) report(result *gofind.FileResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.format == reportNDJSON {
+		json.NewEncoder(r.w).Encode(result)
+		return
+	}
+
+	r.results = append(r.results, result)
+}
+
+// close writes the json report, sorted by the input path
+func (r *reporter) close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.format != reportJSON {
+		return nil
+	}
+
+	sort.Slice(r.results, func(i, j int) bool {
+		return r.results[i].InputPath < r.results[j].InputPath
+	})
+
+	enc := json.NewEncoder(r.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(&jsonReport{Files: r.results})
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/prijip/gofind"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReporter_JSON(t *testing.T) {
+	var out bytes.Buffer
+	r, err := newReporter("json", &out)
+	assert.NoError(t, err)
+
+	r.report(&gofind.FileResult{InputPath: "b", OutputPath: "b", Status: gofind.StatusNoChange})
+	r.report(&gofind.FileResult{
+		InputPath:  "a",
+		OutputPath: "out/a",
+		Status:     gofind.StatusUpdated,
+		Patterns:   []gofind.PatternStats{{Search: "one", Found: 2, Replaced: 1, Filtered: 1}},
+	})
+	assert.NoError(t, r.close())
+
+	expectedOutput := `{
+  "files": [
+    {
+      "input": "a",
+      "output": "out/a",
+      "status": "updated",
+      "patterns": [
+        {
+          "search": "one",
+          "found": 2,
+          "replaced": 1,
+          "filtered": 1
+        }
+      ]
+    },
+    {
+      "input": "b",
+      "output": "b",
+      "status": "no-change"
+    }
+  ]
+}
+`
+	assert.Equal(t, expectedOutput, out.String())
+}
+
+func TestReporter_NDJSON(t *testing.T) {
+	var out bytes.Buffer
+	r, err := newReporter("ndjson", &out)
+	assert.NoError(t, err)
+
+	r.report(&gofind.FileResult{InputPath: "b", OutputPath: "b", Status: gofind.StatusFiltered})
+	r.report(&gofind.FileResult{InputPath: "a", OutputPath: "a", Status: gofind.StatusError, Error: "failed"})
+	assert.NoError(t, r.close())
+
+	expectedOutput := `{"input":"b","output":"b","status":"filtered"}
+{"input":"a","output":"a","status":"error","error":"failed"}
+`
+	assert.Equal(t, expectedOutput, out.String())
+}
+
+func TestReporter_UnknownFormat(t *testing.T) {
+	_, err := newReporter("xml", &bytes.Buffer{})
+	assert.Error(t, err)
+}
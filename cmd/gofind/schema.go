@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"reflect"
+	"strings"
+)
+
+// jsonSchema is the subset of JSON Schema (draft-07) describing the configuration
+type jsonSchema struct {
+	Schema      string `json:"$schema,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Type is a type name, or a list of type names
+	Type       interface{}            `json:"type,omitempty"`
+	Properties map[string]*jsonSchema `json:"properties,omitempty"`
+	// AdditionalProperties is false, or the schema of the values of a map
+	AdditionalProperties interface{}   `json:"additionalProperties,omitempty"`
+	Items                *jsonSchema   `json:"items,omitempty"`
+	AnyOf                []*jsonSchema `json:"anyOf,omitempty"`
+	Pattern              string        `json:"pattern,omitempty"`
+}
+
+// schemaDescriptions are the descriptions of the settings, by path
+// The elements of a list are named with [], e.g. patterns[].search, and * stands for any setting
+var schemaDescriptions = map[string]string{
+	"extends":            "Configuration file merged before this one, relative to this file",
+	"include":            "Configuration files merged after the one named by 'extends', relative to this file",
+	"variables":          "Variables, used as ${var:NAME} in the search, replace, filter and directory settings",
+	"inputDirectory":     "Name of the directory to search for files",
+	"inputDirectories":   "Other directories to search along with inputDirectory",
+	"outputDirectory":    "Name of the directory to place the updated files. If not provided, the original file will be replaced",
+	"preserveTimestamps": "Copy the modification time of the input files on the updated files",
+	"gitIgnore":          "Skip the files and directories ignored by the .gitignore files, and the .git directories",
+	"binaryFiles":        "Apply all the patterns on binary files too",
+	"fileNamePatterns":   "Regular expressions to select the files based on their name. Prefix with 'glob:' for a glob",
+	"fileGlobs":          "Glob patterns to select the files based on their path relative to inputDirectory. Prefix with '!' to ignore the matching files",
+	"encodings":          "Character encoding of the files. The first rule whose fileNamePatterns match applies",
+
+	"encodings[].encoding":         "utf-8, utf-16le, utf-16be, utf-16 or iso-8859-1 (latin1)",
+	"encodings[].fileNamePatterns": "Regular expressions to select the files with this encoding. Prefix with 'glob:' for a glob",
+
+	"lineEndings": "Line endings of the files: lf, crlf or preserve (default)",
+	"counter":     "Sequence of numbers for the replacements, as ${#counter} in 'replace'",
+
+	"counter.start": "First value",
+	"counter.step":  "Increment between values, 1 if zero",
+	"counter.width": "Number of digits the values are padded to with leading zeros",
+
+	"filter":   "Regular expressions to select the files based on their content",
+	"patterns": "Search Replace patterns",
+
+	"patterns[].search":          "Regular expression to search for",
+	"patterns[].replace":         "Text to replace the matches with, expanding $1, ${name}, etc. Unset or null searches only, an empty string deletes the matches",
+	"patterns[].occurrences":     "Number of occurrences to be replaced in each file, 'all' (default) or a number",
+	"patterns[].filter":          "Regular expressions to select the matches to replace",
+	"patterns[].binary":          "Apply the pattern on binary files too",
+	"patterns[].literal":         "Search for the text as it is, instead of a regular expression",
+	"patterns[].ignoreCase":      "Match regardless of the case of letters",
+	"patterns[].wholeWord":       "Skip the matches inside longer words",
+	"patterns[].replaceLiteral":  "Use the replacement text as it is, without expanding $1, ${name}, etc.",
+	"patterns[].replaceTemplate": "Go text/template computing the replacement, used instead of 'replace'",
+	"patterns[].preserveCase":    "Match regardless of case and give the replacement the case of each match",
+
+	"*.include": "Include patterns: the item is selected if any one of them match",
+	"*.exclude": "Exclude patterns: the item is ignored if any one of them match. Exclusion takes priority",
+}
+
+// configSchema returns the JSON Schema of the configuration files
+func configSchema() *jsonSchema {
+	schema := schemaFor(reflect.TypeOf(AppConfig{}), "")
+	schema.Schema = "http://json-schema.org/draft-07/schema#"
+	schema.Title = "gofind configuration"
+	schema.Type = "object"
+
+	return schema
+}
+
+// schemaFor returns the schema of the values of type t, found at path in the configuration
+func schemaFor(t reflect.Type, path string) *jsonSchema {
+	schema := &jsonSchema{Description: schemaDescription(path)}
+
+	switch {
+	case t == reflect.TypeOf(StringOption{}):
+		// Unset (or null) is distinct from an empty string
+		schema.Type = []string{"string", "null"}
+
+	case strings.HasSuffix(path, "[].occurrences"):
+		// A negative number stands for all the occurrences
+		schema.AnyOf = []*jsonSchema{
+			{Type: "string", Pattern: "^(all|-?[0-9]+)$"},
+			{Type: "integer"},
+		}
+
+	case t == reflect.TypeOf(ScalarOption("")):
+		schema.Type = []string{"string", "number", "boolean"}
+
+	case t.Kind() == reflect.String:
+		schema.Type = "string"
+
+	case t.Kind() == reflect.Bool:
+		schema.Type = "boolean"
+
+	case t.Kind() == reflect.Int:
+		schema.Type = "integer"
+
+	case t.Kind() == reflect.Slice:
+		// An empty YAML key, e.g. 'include:', is null
+		schema.Type = []string{"array", "null"}
+		schema.Items = schemaFor(t.Elem(), path+"[]")
+
+	case t.Kind() == reflect.Map:
+		schema.Type = []string{"object", "null"}
+		schema.AdditionalProperties = schemaFor(t.Elem(), path+"[]")
+
+	case t.Kind() == reflect.Struct:
+		schema.Type = []string{"object", "null"}
+		schema.Properties = make(map[string]*jsonSchema)
+		schema.AdditionalProperties = false
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			key := strings.Split(field.Tag.Get("json"), ",")[0]
+			if field.PkgPath != "" || key == "-" {
+				continue
+			}
+			if len(path) > 0 {
+				schema.Properties[key] = schemaFor(field.Type, path+"."+key)
+			} else {
+				schema.Properties[key] = schemaFor(field.Type, key)
+			}
+		}
+
+	default:
+		log.Printf("No JSON Schema for %s of type %v", path, t)
+	}
+
+	return schema
+}
+
+// schemaDescription returns the description of the setting at path
+// The include and exclude lists of the filters share their description
+func schemaDescription(path string) string {
+	if description, ok := schemaDescriptions[path]; ok {
+		return description
+	}
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		return schemaDescriptions["*"+path[i:]]
+	}
+
+	return ""
+}
+
+// doSchema writes the JSON Schema of the configuration files to w
+func doSchema(w io.Writer) int {
+	data, err := json.MarshalIndent(configSchema(), "", "  ")
+	if err == nil {
+		_, err = w.Write(append(data, '\n'))
+	}
+	if err != nil {
+		log.Print("Error writing the schema, err=", err)
+		return exitError
+	}
+
+	return exitOK
+}
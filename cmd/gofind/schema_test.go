@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/stretchr/testify/assert"
+)
+
+// schemaType returns the JSON Schema type of a value decoded by encoding/json
+func schemaType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	}
+
+	return "object"
+}
+
+// checkSchema returns the errors of the value against the schema, for the subset of
+// JSON Schema generated by configSchema
+func checkSchema(schema *jsonSchema, value interface{}, path string) []string {
+	if schema.AnyOf != nil {
+		for _, s := range schema.AnyOf {
+			if len(checkSchema(s, value, path)) == 0 {
+				return nil
+			}
+		}
+		return []string{path + ": matches none of anyOf"}
+	}
+
+	var types []string
+	switch t := schema.Type.(type) {
+	case string:
+		types = []string{t}
+	case []string:
+		types = t
+	}
+	typ := schemaType(value)
+	found := false
+	for _, t := range types {
+		found = found || t == typ || t == "number" && typ == "integer"
+	}
+	if !found {
+		return []string{fmt.Sprintf("%s: %s is not %v", path, typ, types)}
+	}
+
+	var errs []string
+	switch v := value.(type) {
+	case string:
+		if len(schema.Pattern) > 0 && !regexp.MustCompile(schema.Pattern).MatchString(v) {
+			errs = append(errs, fmt.Sprintf("%s: '%s' does not match %s", path, v, schema.Pattern))
+		}
+
+	case []interface{}:
+		for i, item := range v {
+			errs = append(errs, checkSchema(schema.Items, item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			propertySchema, ok := schema.Properties[key]
+			if !ok {
+				if additional, isSchema := schema.AdditionalProperties.(*jsonSchema); isSchema {
+					propertySchema = additional
+				} else if schema.AdditionalProperties == false {
+					errs = append(errs, path+"."+key+": unknown property")
+					continue
+				}
+			}
+			if propertySchema != nil {
+				errs = append(errs, checkSchema(propertySchema, v[key], path+"."+key)...)
+			}
+		}
+	}
+
+	return errs
+}
+
+// checkConfigSchema returns the errors of the configuration file against the schema
+func checkConfigSchema(t *testing.T, data []byte) []string {
+	data, err := yaml.YAMLToJSON(data)
+	assert.NoError(t, err)
+
+	var value interface{}
+	assert.NoError(t, json.Unmarshal(data, &value))
+
+	return checkSchema(configSchema(), value, "$")
+}
+
+func TestSchema_Published(t *testing.T) {
+	var out bytes.Buffer
+	assert.Equal(t, exitOK, doSchema(&out))
+
+	published, err := ioutil.ReadFile(filepath.Join("..", "..", "gofind.schema.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, string(published), out.String(), "gofind.schema.json is out of date, update it with 'gofind schema'")
+}
+
+func TestSchema_Configs(t *testing.T) {
+	for _, configFile := range []string{"testdata/config.yaml", "testdata/config.json"} {
+		data, err := ioutil.ReadFile(configFile)
+		assert.NoError(t, err)
+		assert.Empty(t, checkConfigSchema(t, data), configFile)
+	}
+
+	assert.Empty(t, checkConfigSchema(t, templateConfigData))
+}
+
+func TestSchema_Invalid(t *testing.T) {
+	assert.Equal(t, []string{
+		"$.gitIgnore: string is not [boolean]",
+		"$.patterns[0].occurrences: matches none of anyOf",
+		"$.patterns[0].serach: unknown property",
+		"$.patterns[1].occurrences: matches none of anyOf",
+		"$.variables.list: array is not [string number boolean]",
+	}, checkConfigSchema(t, []byte(`
+gitIgnore: "yes"
+variables: {year: 2019, list: [2019]}
+patterns:
+- serach: one
+  occurrences: first
+- search: two
+  replace: null
+  occurrences: 1.5
+- search: three
+  replace: ""
+  occurrences: 2
+- search: four
+  occurrences: all
+`)))
+}
+
+func TestSchema_Load(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// Settings the schema accepts load from every format
+	files := map[string]string{
+		"config.json": `{
+	"variables": {"year": 2019, "draft": true, "name": "gofind"},
+	"patterns": [
+		{"search": "one", "occurrences": 2},
+		{"search": "two", "occurrences": "all"},
+		{"search": "three", "occurrences": -1}
+	]
+}`,
+		"config.yaml": `variables: {year: 2019, draft: true, name: gofind}
+patterns:
+- search: one
+  occurrences: 2
+- search: two
+  occurrences: all
+- search: three
+  occurrences: "-1"
+`,
+		"config.toml": `[variables]
+year = 2019
+draft = true
+name = "gofind"
+
+[[patterns]]
+search = "one"
+occurrences = 2
+
+[[patterns]]
+search = "two"
+occurrences = "all"
+
+[[patterns]]
+search = "three"
+occurrences = -1
+`,
+	}
+	writeConfigFiles(t, dir, files)
+
+	for name, data := range files {
+		if filepath.Ext(name) != ".toml" {
+			assert.Empty(t, checkConfigSchema(t, []byte(data)), name)
+		}
+
+		cfg, err := loadConfig(filepath.Join(dir, name))
+		assert.NoError(t, err, name)
+		assert.Equal(t, map[string]ScalarOption{"year": "2019", "draft": "true", "name": "gofind"}, cfg.Variables, name)
+		if assert.Len(t, cfg.Patterns, 3, name) {
+			assert.Equal(t, ScalarOption("2"), cfg.Patterns[0].Occurrences, name)
+			assert.Equal(t, ScalarOption("all"), cfg.Patterns[1].Occurrences, name)
+			assert.Equal(t, ScalarOption("-1"), cfg.Patterns[2].Occurrences, name)
+		}
+		// A negative number of occurrences stands for all of them, as it always did
+		assert.Empty(t, validateConfig(&cfg, nil, false), name)
+	}
+}
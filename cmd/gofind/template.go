@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"regexp"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/prijip/gofind"
+)
+
+// templateFuncs are the functions available in the replace templates, along with
+// the text/template builtins (printf, len, index, etc.)
+var templateFuncs = template.FuncMap{
+	"upper":  strings.ToUpper,
+	"lower":  strings.ToLower,
+	"title":  title,
+	"camel":  camel,
+	"pascal": pascal,
+	"snake":  func(s string) string { return joinWords(s, "_") },
+	"kebab":  func(s string) string { return joinWords(s, "-") },
+	"trim":   strings.TrimSpace,
+}
+
+// replaceFuncFromTemplate compiles a replace template for the matches of re
+// The template is executed with .Match, the text of the match, .Groups, the text of
+// the match followed by the text of each capture group, and the text of each named
+// capture group by its name, e.g. {{pascal .name}} or {{upper (index .Groups 1)}}
+// .Index and .Occurrence are the numbers given by ${#index} and ${#occurrence} in 'replace'
+func replaceFuncFromTemplate(text string, re *regexp.Regexp) (func(m *gofind.Submatch) []byte, error) {
+	tmpl, err := parseReplaceTemplate(text)
+	if err != nil {
+		return nil, err
+	}
+
+	names := re.SubexpNames()
+	return func(m *gofind.Submatch) []byte {
+		groups := make([]string, len(m.Groups))
+		data := make(map[string]interface{})
+		for i, group := range m.Groups {
+			groups[i] = string(group)
+			if i < len(names) && len(names[i]) > 0 {
+				data[names[i]] = groups[i]
+			}
+		}
+		data["Match"] = groups[0]
+		data["Groups"] = groups
+		data["Index"] = m.Index
+		data["Occurrence"] = m.Occurrence
+
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			log.Printf("Failed to execute the replace template, match left unchanged. err=%v", err)
+			return m.Groups[0]
+		}
+
+		return buf.Bytes()
+	}, nil
+}
+
+// parseReplaceTemplate parses a replace template, with the templateFuncs
+func parseReplaceTemplate(text string) (*template.Template, error) {
+	return template.New("replaceTemplate").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+}
+
+// splitWords splits s into words, at non alphanumeric characters and at case changes
+// e.g. "get_user_name", "getUserName" and "GetUserName" all give get, user and name
+// (with their original case). A run of upper case letters is one word, as in "HTTPServer"
+func splitWords(s string) []string {
+	var words []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, string(word))
+			word = nil
+		}
+	}
+
+	runes := []rune(s)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+
+		if len(word) > 0 && unicode.IsUpper(r) {
+			prev := word[len(word)-1]
+			// An upper case letter starts a word, unless it continues a run of upper
+			// case letters that is not followed by a lower case letter
+			if !unicode.IsUpper(prev) || (i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				flush()
+			}
+		}
+		word = append(word, r)
+	}
+	flush()
+
+	return words
+}
+
+// capitalize returns the word with its first letter in upper case and the others in lower case
+func capitalize(word string) string {
+	runes := []rune(strings.ToLower(word))
+	if len(runes) > 0 {
+		runes[0] = unicode.ToUpper(runes[0])
+	}
+
+	return string(runes)
+}
+
+// title returns s with the first letter of each word in upper case, leaving the rest as it is
+func title(s string) string {
+	runes := []rune(s)
+	for i, r := range runes {
+		if i == 0 || !unicode.IsLetter(runes[i-1]) && !unicode.IsDigit(runes[i-1]) {
+			runes[i] = unicode.ToUpper(r)
+		}
+	}
+
+	return string(runes)
+}
+
+// camel returns the words of s joined in lower camel case, e.g. getUserName
+func camel(s string) string {
+	words := splitWords(s)
+	for i := range words {
+		if i == 0 {
+			words[i] = strings.ToLower(words[i])
+		} else {
+			words[i] = capitalize(words[i])
+		}
+	}
+
+	return strings.Join(words, "")
+}
+
+// pascal returns the words of s joined in upper camel case, e.g. GetUserName
+func pascal(s string) string {
+	words := splitWords(s)
+	for i := range words {
+		words[i] = capitalize(words[i])
+	}
+
+	return strings.Join(words, "")
+}
+
+// joinWords returns the words of s in lower case joined with sep, e.g. get_user_name
+func joinWords(s, sep string) string {
+	words := splitWords(s)
+	for i := range words {
+		words[i] = strings.ToLower(words[i])
+	}
+
+	return strings.Join(words, sep)
+}
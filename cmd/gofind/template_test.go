@@ -0,0 +1,48 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/prijip/gofind"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitWords(t *testing.T) {
+	assert.Equal(t, []string{"get", "user", "name"}, splitWords("get_user_name"))
+	assert.Equal(t, []string{"get", "User", "Name"}, splitWords("getUserName"))
+	assert.Equal(t, []string{"HTTP", "Server", "2"}, splitWords("HTTPServer-2"))
+	assert.Equal(t, []string{"ID"}, splitWords("ID"))
+	assert.Nil(t, splitWords(" _ "))
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	assert.Equal(t, "getUserName", camel("get_user_name"))
+	assert.Equal(t, "GetUserName", pascal("get_user_name"))
+	assert.Equal(t, "HttpServer", pascal("HTTPServer"))
+	assert.Equal(t, "get_user_name", joinWords("GetUserName", "_"))
+	assert.Equal(t, "get-user-name", joinWords("getUserName", "-"))
+	assert.Equal(t, "Get User_Name", title("get user_name"))
+}
+
+func TestReplaceFuncFromTemplate(t *testing.T) {
+	re := regexp.MustCompile(`func (?P<name>\w+)\((\w*)\)`)
+	replaceFunc, err := replaceFuncFromTemplate(`func {{pascal .name}}({{printf "%q" (index .Groups 2)}}) // {{upper .Match}}`, re)
+	assert.NoError(t, err)
+
+	patterns := []gofind.SearchReplacePattern{{SearchRegex: re, ReplaceFunc: replaceFunc, Occurrences: -1}}
+	replaced, err := gofind.SearchReplace([]byte("func get_user_name(id)"), patterns)
+	assert.NoError(t, err)
+	assert.Equal(t, `func GetUserName("id") // FUNC GET_USER_NAME(ID)`, string(replaced))
+
+	// Templates failing to execute leave the match unchanged
+	replaceFunc, err = replaceFuncFromTemplate(`{{.missing}}`, re)
+	assert.NoError(t, err)
+	patterns[0].ReplaceFunc = replaceFunc
+	replaced, err = gofind.SearchReplace([]byte("func f()"), patterns)
+	assert.NoError(t, err)
+	assert.Equal(t, "func f()", string(replaced))
+
+	_, err = replaceFuncFromTemplate(`{{unknown .name}}`, re)
+	assert.Error(t, err)
+}
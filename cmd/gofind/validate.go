@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/prijip/gofind"
+)
+
+// diagnostic is a problem found in the configuration
+type diagnostic struct {
+	// file is empty for the settings given on the command line, line is 0 if unknown
+	file    string
+	line    int
+	field   string
+	message string
+}
+
+func (d diagnostic) String() string {
+	var parts []string
+	switch {
+	case len(d.file) == 0:
+	case d.line == 0:
+		parts = append(parts, d.file)
+	default:
+		parts = append(parts, d.file+":"+strconv.Itoa(d.line))
+	}
+	// The field is empty for the problems with a whole file, like a syntax error
+	if len(d.field) > 0 {
+		parts = append(parts, d.field)
+	}
+
+	return strings.Join(append(parts, d.message), ": ")
+}
+
+// Error makes a diagnostic the error of loading the configuration
+func (d diagnostic) Error() string {
+	return d.String()
+}
+
+// validator collects the problems of a configuration, located with the source of the configuration
+type validator struct {
+	src         *configSource
+	diagnostics []diagnostic
+}
+
+// report adds a problem with the setting at path in the merged configuration
+func (v *validator) report(path string, format string, args ...interface{}) {
+	v.diagnostics = append(v.diagnostics, v.src.diagnostic(path, fmt.Sprintf(format, args...)))
+}
+
+// checkRegex reports the regular expression if it does not compile
+func (v *validator) checkRegex(path, expr string) {
+	if _, err := regexp.Compile(expr); err != nil {
+		v.report(path, "%v", err)
+	}
+}
+
+// checkGlob reports the glob if it does not compile
+func (v *validator) checkGlob(path, glob string) {
+	if _, err := gofind.CompileGlob(glob); err != nil {
+		v.report(path, "%v", err)
+	}
+}
+
+// checkFilter reports the regular expressions of the filter that do not compile
+// File name filters may hold globs, with the globPrefix
+func (v *validator) checkFilter(path string, filter FilterOptions, fileNames bool) {
+	check := func(list []string, path string) {
+		for i, pattern := range list {
+			itemPath := path + "[" + strconv.Itoa(i) + "]"
+			if fileNames && strings.HasPrefix(pattern, globPrefix) {
+				v.checkGlob(itemPath, strings.TrimPrefix(pattern, globPrefix))
+			} else {
+				v.checkRegex(itemPath, pattern)
+			}
+		}
+	}
+
+	check(filter.Include, path+".include")
+	check(filter.Exclude, path+".exclude")
+}
+
+// checkDirectory reports the directory if it does not exist
+func (v *validator) checkDirectory(path, dir string) {
+	info, err := os.Stat(dir)
+	switch {
+	case os.IsNotExist(err):
+		v.report(path, "Directory '%s' does not exist", dir)
+	case err != nil:
+		v.report(path, "%v", err)
+	case !info.IsDir():
+		v.report(path, "'%s' is not a directory", dir)
+	}
+}
+
+// validateConfig returns all the problems of the configuration, located in the
+// configuration files by src: unknown keys, invalid regular expressions, globs,
+// occurrences, templates, encodings and line endings, and, if checkDirs is set,
+// input directories that do not exist
+func validateConfig(cfg *AppConfig, src *configSource, checkDirs bool) []diagnostic {
+	v := validator{src: src}
+	if src != nil {
+		v.diagnostics = append(v.diagnostics, src.problems...)
+	}
+
+	for i := range cfg.Patterns {
+		pattern := &cfg.Patterns[i]
+		path := "patterns[" + strconv.Itoa(i) + "]"
+
+		if len(pattern.Search) == 0 {
+			v.report(path+".search", "Search text is empty")
+		} else if _, err := searchRegexFromOption(pattern); err != nil {
+			v.report(path+".search", "%v", err)
+		}
+
+		if _, err := parseOccurrences(string(pattern.Occurrences)); err != nil {
+			v.report(path+".occurrences", "%v", err)
+		}
+
+		if len(pattern.ReplaceTemplate) > 0 {
+			if _, err := parseReplaceTemplate(pattern.ReplaceTemplate); err != nil {
+				v.report(path+".replaceTemplate", "%v", err)
+			}
+		}
+
+		v.checkFilter(path+".filter", pattern.Filter, false)
+	}
+
+	v.checkFilter("filter", cfg.Filter, false)
+	v.checkFilter("fileNamePatterns", cfg.FileNames, true)
+	for i, glob := range cfg.FileGlobs {
+		v.checkGlob("fileGlobs["+strconv.Itoa(i)+"]", strings.TrimPrefix(glob, "!"))
+	}
+
+	for i := range cfg.Encodings {
+		path := "encodings[" + strconv.Itoa(i) + "]"
+		if _, err := gofind.LookupEncoding(cfg.Encodings[i].Encoding); err != nil {
+			v.report(path+".encoding", "%v", err)
+		}
+		v.checkFilter(path+".fileNamePatterns", cfg.Encodings[i].FileNames, true)
+	}
+
+	if _, err := gofind.ParseLineEnding(cfg.LineEndings); err != nil {
+		v.report("lineEndings", "%v", err)
+	}
+
+	if checkDirs {
+		if len(cfg.InputDirectory) > 0 {
+			v.checkDirectory("inputDirectory", cfg.InputDirectory)
+		}
+		for i, dir := range cfg.InputDirectories {
+			v.checkDirectory("inputDirectories["+strconv.Itoa(i)+"]", dir)
+		}
+	}
+
+	return v.diagnostics
+}
+
+// checkConfig logs the problems of the configuration, returns false if there is any
+func checkConfig(checkDirs bool) bool {
+	diagnostics := validateConfig(&config, configSrc, checkDirs)
+	for _, d := range diagnostics {
+		log.Print(d)
+	}
+	if len(diagnostics) > 0 {
+		log.Print(len(diagnostics), " problem(s) found in the configuration")
+		return false
+	}
+
+	return true
+}
+
+// doValidate writes the problems of the configuration to w
+func doValidate(w io.Writer) int {
+	diagnostics := validateConfig(&config, configSrc, true)
+	for _, d := range diagnostics {
+		fmt.Fprintln(w, d)
+	}
+	if len(diagnostics) > 0 {
+		log.Print(len(diagnostics), " problem(s) found in the configuration")
+		return exitError
+	}
+
+	log.Print("Configuration is valid")
+	return exitOK
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func diagnosticStrings(diagnostics []diagnostic) []string {
+	var lines []string
+	for _, d := range diagnostics {
+		lines = append(lines, d.String())
+	}
+
+	return lines
+}
+
+func TestValidateConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	writeConfigFiles(t, dir, map[string]string{
+		"base.yaml": `inputDirectory: missing
+patterns:
+- search: one
+  replace: ONE
+- search: '(two'
+  occurrences: first
+`,
+		"extra.json": `{
+	"patterns": [{
+		"search": "three",
+		"filter": {"include": ["[a-"]}
+	}],
+	"lineEndings": "cr"
+}`,
+		"gofind.yaml": `extends: base.yaml
+include: [extra.json]
+fileGlobs: ['[a-']
+patterns:
+- search: four
+  replce: FOUR
+- search: five
+  replaceTemplate: '{{upper .Match'
+`,
+	})
+
+	cfg, src, err := loadConfigSource(filepath.Join(dir, "gofind.yaml"))
+	assert.NoError(t, err)
+	cfg.Patterns = append(cfg.Patterns, SearchReplaceOption{Search: "six", Occurrences: "1.5"})
+
+	base := filepath.Join(dir, "base.yaml")
+	extra := filepath.Join(dir, "extra.json")
+	top := filepath.Join(dir, "gofind.yaml")
+	assert.Equal(t, []string{
+		top + ":6: patterns[0].replce: Unknown key",
+		base + ":5: patterns[1].search: error parsing regexp: missing closing ): `(two`",
+		base + ":6: patterns[1].occurrences: Invalid occurrences 'first', expected 'all' or a number",
+		extra + ":4: patterns[0].filter.include[0]: error parsing regexp: missing closing ]: `[a-`",
+		top + ":8: patterns[1].replaceTemplate: template: replaceTemplate:1: unclosed action",
+		"patterns[5].occurrences: Invalid occurrences '1.5', expected 'all' or a number",
+		top + ":3: fileGlobs[0]: Invalid glob '[a-'. err=missing ']'",
+		extra + ":6: lineEndings: Unknown line ending 'cr', expected lf, crlf or preserve",
+		base + ":1: inputDirectory: Directory 'missing' does not exist",
+	}, diagnosticStrings(validateConfig(&cfg, src, true)))
+
+	// Directories are only checked on request
+	assert.Len(t, validateConfig(&cfg, src, false), 8)
+}
+
+func TestValidateConfig_Valid(t *testing.T) {
+	for _, configFile := range []string{"testdata/config.yaml", "testdata/config.json"} {
+		cfg, src, err := loadConfigSource(configFile)
+		assert.NoError(t, err)
+		assert.Empty(t, validateConfig(&cfg, src, true), configFile)
+	}
+}
+
+func TestRun_Validate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	writeConfigFiles(t, dir, map[string]string{
+		"gofind.yaml": `inputDirectory: testdata/input
+patterns:
+- search: '(one'
+`,
+	})
+
+	assert.NoError(t, flag.Set("config", filepath.Join(dir, "gofind.yaml")))
+	defer flag.Set("config", "")
+
+	// A run with an invalid configuration is aborted before any file is processed
+	assert.Equal(t, exitError, run())
+
+	args := os.Args
+	os.Args = []string{args[0], "validate"}
+	defer func() { os.Args = args }()
+
+	assert.NoError(t, parseFlags())
+	var out bytes.Buffer
+	assert.Equal(t, exitError, doValidate(&out))
+	assert.Equal(t, filepath.Join(dir, "gofind.yaml")+":3: patterns[0].search: error parsing regexp: missing closing ): `(one`\n", out.String())
+}
+
+func TestValidateConfig_UndefinedVariables(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	writeConfigFiles(t, dir, map[string]string{
+		"base.yaml": `variables:
+  home: ${env:GOFIND_TEST_MISSING}
+`,
+		"gofind.yaml": `extends: base.yaml
+inputDirectory: missing
+patterns:
+- search: '(${var:nope}'
+  replce: x
+  occurrences: first
+  filter: {include: ['${var:other}[a-']}
+`,
+	})
+
+	assert.NoError(t, flag.Set("config", filepath.Join(dir, "gofind.yaml")))
+	defer flag.Set("config", "")
+	assert.NoError(t, parseFlags())
+
+	// The undefined variables do not hide the other problems
+	base := filepath.Join(dir, "base.yaml")
+	top := filepath.Join(dir, "gofind.yaml")
+	assert.Equal(t, []string{
+		top + ":5: patterns[0].replce: Unknown key",
+		base + ":2: variables.home: Undefined environment variable 'GOFIND_TEST_MISSING'",
+		top + ":4: patterns[0].search: Undefined variable 'nope'",
+		top + ":7: patterns[0].filter.include[0]: Undefined variable 'other'",
+		top + ":4: patterns[0].search: error parsing regexp: missing closing ): `(${var:nope}`",
+		top + ":6: patterns[0].occurrences: Invalid occurrences 'first', expected 'all' or a number",
+		top + ":7: patterns[0].filter.include[0]: error parsing regexp: missing closing ]: `[a-`",
+		top + ":2: inputDirectory: Directory 'missing' does not exist",
+	}, diagnosticStrings(validateConfig(&config, configSrc, true)))
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// variableRef matches the references to a variable, ${var:NAME}, or to an environment variable, ${env:NAME}
+var variableRef = regexp.MustCompile(`\$\{(var|env):([^}]*)\}`)
+
+// varsFlag collects the -var key=value command line options
+type varsFlag map[string]string
+
+func (v varsFlag) String() string {
+	var pairs []string
+	for key, value := range v {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
+
+// Set is called by flag.Parse
+func (v varsFlag) Set(val string) error {
+	i := strings.Index(val, "=")
+	if i <= 0 {
+		return fmt.Errorf("Expected key=value, got '%s'", val)
+	}
+	v[val[:i]] = val[i+1:]
+
+	return nil
+}
+
+// expandVariables replaces the references to variables in s, and returns an error for each undefined one
+// vars is nil while the variables themselves are expanded, only the environment is available then
+func expandVariables(s string, vars map[string]string) (string, []error) {
+	var errs []error
+	expanded := variableRef.ReplaceAllStringFunc(s, func(ref string) string {
+		m := variableRef.FindStringSubmatch(ref)
+		kind, name := m[1], m[2]
+
+		var value string
+		var ok bool
+		if kind == "env" {
+			value, ok = os.LookupEnv(name)
+		} else if vars != nil {
+			value, ok = vars[name]
+		}
+		if !ok {
+			if kind == "env" {
+				errs = append(errs, fmt.Errorf("Undefined environment variable '%s'", name))
+			} else {
+				errs = append(errs, fmt.Errorf("Undefined variable '%s'", name))
+			}
+			// The reference is left as it is, e.g. so that the search text is not seen as empty
+			return ref
+		}
+
+		return value
+	})
+
+	return expanded, errs
+}
+
+// substituteVariables replaces the references to variables and environment variables in
+// the search, replace, filter and directory fields of the configuration
+// The variables of the configuration are overridden by overrides
+// Returns a diagnostic for each undefined variable, located in the files by src
+func substituteVariables(cfg *AppConfig, overrides map[string]string, src *configSource) []diagnostic {
+	var diagnostics []diagnostic
+	expandField := func(s, field string, vars map[string]string) string {
+		expanded, errs := expandVariables(s, vars)
+		for _, err := range errs {
+			diagnostics = append(diagnostics, src.diagnostic(field, err.Error()))
+		}
+		return expanded
+	}
+
+	names := make([]string, 0, len(cfg.Variables))
+	for name := range cfg.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	vars := make(map[string]string)
+	for _, name := range names {
+		vars[name] = expandField(string(cfg.Variables[name]), "variables."+name, nil)
+	}
+	for name, value := range overrides {
+		vars[name] = value
+	}
+
+	expand := func(s *string, field string) {
+		*s = expandField(*s, field, vars)
+	}
+	expandAll := func(list []string, field string) {
+		for i := range list {
+			expand(&list[i], field+"["+strconv.Itoa(i)+"]")
+		}
+	}
+	expandFilter := func(filter *FilterOptions, field string) {
+		expandAll(filter.Include, field+".include")
+		expandAll(filter.Exclude, field+".exclude")
+	}
+
+	expand(&cfg.InputDirectory, "inputDirectory")
+	expandAll(cfg.InputDirectories, "inputDirectories")
+	expand(&cfg.OutputDirectory, "outputDirectory")
+	expandFilter(&cfg.FileNames, "fileNamePatterns")
+	expandAll(cfg.FileGlobs, "fileGlobs")
+	expandFilter(&cfg.Filter, "filter")
+	for i := range cfg.Encodings {
+		expandFilter(&cfg.Encodings[i].FileNames, "encodings["+strconv.Itoa(i)+"].fileNamePatterns")
+	}
+
+	for i := range cfg.Patterns {
+		pattern := &cfg.Patterns[i]
+		field := "patterns[" + strconv.Itoa(i) + "]"
+		expand(&pattern.Search, field+".search")
+		if pattern.Replace.IsValid() {
+			expand(&pattern.Replace.value, field+".replace")
+		}
+		expand(&pattern.ReplaceTemplate, field+".replaceTemplate")
+		expandFilter(&pattern.Filter, field+".filter")
+	}
+
+	return diagnostics
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVarsFlag(t *testing.T) {
+	v := varsFlag{}
+	assert.NoError(t, v.Set("year=2020"))
+	assert.NoError(t, v.Set("name=a=b"))
+	assert.Error(t, v.Set("year"))
+	assert.Equal(t, "name=a=b,year=2020", v.String())
+}
+
+func TestSubstituteVariables(t *testing.T) {
+	os.Setenv("GOFIND_TEST_DIR", "/tmp/in")
+	defer os.Unsetenv("GOFIND_TEST_DIR")
+
+	cfg := AppConfig{
+		InputDirectory: "${env:GOFIND_TEST_DIR}",
+		Variables: map[string]ScalarOption{
+			"company": "Foo",
+			"year":    "2019",
+			"dir":     "${env:GOFIND_TEST_DIR}/sub",
+		},
+		FileGlobs: []string{"${var:dir}/*.go"},
+		Patterns: []SearchReplaceOption{{
+			Search:  `Copyright \d+ ${var:company}`,
+			Replace: StringOption{valid: true, value: "Copyright ${var:year} ${var:company}"},
+			Filter:  FilterOptions{Exclude: []string{"${var:year}"}},
+		}},
+	}
+
+	assert.Empty(t, substituteVariables(&cfg, map[string]string{"year": "2020"}, nil))
+	assert.Equal(t, "/tmp/in", cfg.InputDirectory)
+	assert.Equal(t, []string{"/tmp/in/sub/*.go"}, cfg.FileGlobs)
+	assert.Equal(t, `Copyright \d+ Foo`, cfg.Patterns[0].Search)
+	assert.Equal(t, "Copyright 2020 Foo", cfg.Patterns[0].Replace.String())
+	assert.Equal(t, []string{"2020"}, cfg.Patterns[0].Filter.Exclude)
+
+	// Capture groups are left for the regular expression
+	cfg = AppConfig{Patterns: []SearchReplaceOption{{Search: "(?P<x>a)", Replace: StringOption{valid: true, value: "${x}$1"}}}}
+	assert.Empty(t, substituteVariables(&cfg, nil, nil))
+	assert.Equal(t, "${x}$1", cfg.Patterns[0].Replace.String())
+
+	// Every undefined variable is reported
+	cfg = AppConfig{
+		OutputDirectory: "${env:GOFIND_TEST_MISSING}",
+		Patterns:        []SearchReplaceOption{{Search: "${var:missing}${var:other}"}},
+	}
+	assert.Equal(t, []string{
+		"outputDirectory: Undefined environment variable 'GOFIND_TEST_MISSING'",
+		"patterns[0].search: Undefined variable 'missing'",
+		"patterns[0].search: Undefined variable 'other'",
+	}, diagnosticStrings(substituteVariables(&cfg, nil, nil)))
+}
+
+func TestParseFlags_Var(t *testing.T) {
+	assert.NoError(t, flag.Set("config", "testdata/config.json"))
+	assert.NoError(t, flag.Set("search", "${var:word}"))
+	defer flag.Set("search", "")
+	assert.NoError(t, flag.Set("var", "word=three"))
+	defer delete(cliVars, "word")
+
+	err := parseFlags()
+	assert.NoError(t, err)
+	assert.Equal(t, "three", config.Patterns[1].Search)
+
+	// An undefined variable is a problem of the configuration
+	delete(cliVars, "word")
+	err = parseFlags()
+	assert.NoError(t, err)
+	assert.False(t, checkConfig(false))
+}
@@ -0,0 +1,92 @@
+package gofind
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// Variables expanded in the ReplacePattern of a SearchReplacePattern, before the
+// capture groups ($1, ${name}, etc.)
+const (
+	// VarIndex is the number of the replacement in the content, counting the
+	// replacements of all the patterns, from 1
+	VarIndex = "${#index}"
+	// VarOccurrence is the number of the match among the matches of the pattern in
+	// the content, from 1. Matches that are filtered out or declined are counted too
+	VarOccurrence = "${#occurrence}"
+	// VarCounter is the next value of the Counter of the pattern
+	// It is left as it is if the pattern has no Counter
+	VarCounter = "${#counter}"
+)
+
+// Counter is a sequence of numbers shared by the replacements of a run, across files
+// Values are taken in the order the matches are replaced; when files are processed
+// in parallel, that order is not deterministic
+// It is safe for concurrent use
+type Counter struct {
+	// Start is the first value
+	Start int
+	// Step is the increment between values, 1 if zero
+	Step int
+	// Width pads the values with leading zeros up to this number of digits
+	Width int
+
+	mu    sync.Mutex
+	count int
+}
+
+// Next returns the next value of the sequence, padded to Width
+func (c *Counter) Next() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.count++
+	return c.value(c.count - 1)
+}
+
+// peek returns the value Next will return, without taking it
+func (c *Counter) peek() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.value(c.count)
+}
+
+// value returns the value at position i of the sequence, padded to Width
+func (c *Counter) value(i int) string {
+	step := c.Step
+	if step == 0 {
+		step = 1
+	}
+
+	return fmt.Sprintf("%0*d", c.Width, c.Start+i*step)
+}
+
+// hasVars reports whether the replacement pattern uses any variable
+func hasVars(replacePattern []byte) bool {
+	return bytes.Contains(replacePattern, []byte("${#"))
+}
+
+// usesCounter reports whether the replacement pattern takes values from the counter
+func usesCounter(replacePattern []byte, counter *Counter) bool {
+	return counter != nil && bytes.Contains(replacePattern, []byte(VarCounter))
+}
+
+// expandVars returns the replacement pattern with the variables replaced by their value
+// The value of the counter is taken only if take is set, it is only peeked at otherwise,
+// e.g. to show the replacement of a match that may be declined
+func expandVars(replacePattern []byte, index, occurrence int, counter *Counter, take bool) []byte {
+	expanded := bytes.Replace(replacePattern, []byte(VarIndex), []byte(strconv.Itoa(index)), -1)
+	expanded = bytes.Replace(expanded, []byte(VarOccurrence), []byte(strconv.Itoa(occurrence)), -1)
+	if usesCounter(expanded, counter) {
+		value := counter.peek()
+		if take {
+			value = counter.Next()
+		}
+		expanded = bytes.Replace(expanded, []byte(VarCounter), []byte(value), -1)
+	}
+
+	return expanded
+}
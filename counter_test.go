@@ -0,0 +1,109 @@
+package gofind
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounter(t *testing.T) {
+	c := &Counter{}
+	assert.Equal(t, "0", c.Next())
+	assert.Equal(t, "1", c.Next())
+
+	c = &Counter{Start: 10, Step: 5, Width: 3}
+	assert.Equal(t, "010", c.Next())
+	assert.Equal(t, "015", c.Next())
+}
+
+func TestSearchReplace_Vars(t *testing.T) {
+	testData := []byte("[x] [y] [skip] [z] {a} {b}")
+
+	counter := &Counter{Start: 1, Width: 2}
+	patterns := []SearchReplacePattern{
+		SearchReplacePattern{
+			SearchRegex:    makeRegex(t, `\[(\w+)\]`),
+			ReplacePattern: []byte("[$1 ${#index}.${#occurrence}.${#counter}]"),
+			Occurrences:    -1,
+			Filter:         &Filter{Exclude: []*regexp.Regexp{makeRegex(t, "skip")}},
+			Counter:        counter,
+		},
+		SearchReplacePattern{
+			SearchRegex:    makeRegex(t, `\{(\w+)\}`),
+			ReplacePattern: []byte("{$1 ${#index}.${#occurrence}}"),
+			Occurrences:    -1,
+		},
+	}
+
+	replaced, err := SearchReplace(testData, patterns)
+	assert.NoError(t, err)
+	assert.Equal(t, "[x 1.1.01] [y 2.2.02] [skip] [z 3.4.03] {a 4.1} {b 5.2}", string(replaced))
+
+	// The counter goes on across calls, without a counter the variable is left as it is
+	patterns[0].Filter = nil
+	replaced, err = SearchReplace([]byte("[x]"), patterns)
+	assert.NoError(t, err)
+	assert.Equal(t, "[x 1.1.04]", string(replaced))
+
+	patterns[0].Counter = nil
+	replaced, err = SearchReplace([]byte("[x]"), patterns)
+	assert.NoError(t, err)
+	assert.Equal(t, "[x 1.1.${#counter}]", string(replaced))
+}
+
+func TestSearchReplaceConfirm_Counter(t *testing.T) {
+	patterns := []SearchReplacePattern{
+		SearchReplacePattern{
+			SearchRegex:    makeRegex(t, `id`),
+			ReplacePattern: []byte("id${#counter}"),
+			Occurrences:    -1,
+			Counter:        &Counter{Start: 1, Width: 3},
+		},
+	}
+
+	// The replacement shown for a declined match does not use up a value
+	var shown []string
+	decisions := []Decision{ReplaceNo, ReplaceYes, ReplaceAll}
+	replaced, err := SearchReplaceConfirm([]byte("id id id id"), patterns, func(m *Match) Decision {
+		shown = append(shown, string(m.Replacement))
+		decision := decisions[0]
+		decisions = decisions[1:]
+		return decision
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "id id001 id002 id003", string(replaced))
+	assert.Equal(t, []string{"id001", "id001", "id002"}, shown)
+}
+
+func TestFileSearchReplaceWithOptions_Counter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	patterns := []SearchReplacePattern{
+		SearchReplacePattern{
+			SearchRegex:    makeRegex(t, `id`),
+			ReplacePattern: []byte("id${#counter}"),
+			Occurrences:    -1,
+			Counter:        &Counter{Start: 1},
+		},
+	}
+
+	// The counter is shared across files, streamed or not
+	var contents []string
+	for i, opts := range []FileOptions{{}, {StreamThreshold: 1}} {
+		path := filepath.Join(dir, strconv.Itoa(i))
+		assert.NoError(t, ioutil.WriteFile(path, []byte("id id"), 0644))
+		_, err = FileSearchReplaceWithOptions(path, path, patterns, nil, opts)
+		assert.NoError(t, err)
+		data, err := ioutil.ReadFile(path)
+		assert.NoError(t, err)
+		contents = append(contents, string(data))
+	}
+	assert.Equal(t, []string{"id1 id2", "id3 id4"}, contents)
+}
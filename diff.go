@@ -0,0 +1,235 @@
+package gofind
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// DiffContextLines is the number of unchanged lines written around each change
+// in a unified diff
+const DiffContextLines = 3
+
+// diffOp is one line of an edit script
+// kind is ' ' for an unchanged line, '-' for a deleted line and '+' for an inserted line
+type diffOp struct {
+	kind byte
+	line []byte
+}
+
+// splitLines splits data into lines, keeping the line terminators
+func splitLines(data []byte) [][]byte {
+	var lines [][]byte
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			lines = append(lines, data)
+			break
+		}
+		lines = append(lines, data[:i+1])
+		data = data[i+1:]
+	}
+
+	return lines
+}
+
+// maxDiffEdits is the largest number of edits searched for by diffLines
+// Beyond it, the lines that differ are given as deleted then inserted, in a single hunk
+// The memory used by the search grows with the square of the number of edits
+const maxDiffEdits = 2000
+
+// diffLines computes the shortest edit script to turn a into b
+// using the Myers difference algorithm
+func diffLines(a, b [][]byte) []diffOp {
+	// The lines common to the start and to the end are not searched
+	var prefix, suffix int
+	for prefix < len(a) && prefix < len(b) && bytes.Equal(a[prefix], b[prefix]) {
+		prefix++
+	}
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && bytes.Equal(a[len(a)-1-suffix], b[len(b)-1-suffix]) {
+		suffix++
+	}
+
+	var ops []diffOp
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	ops = append(ops, diffMiddle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+
+	return ops
+}
+
+// diffMiddle computes the edit script of diffLines, for lines that differ at both ends
+func diffMiddle(a, b [][]byte) []diffOp {
+	n, m := len(a), len(b)
+	max := n + m
+	if max > maxDiffEdits {
+		max = maxDiffEdits
+	}
+	offset := max + 1
+	v := make([]int, 2*max+3)
+
+	// Keep the diagonals -d..d of v for every edit distance d, to backtrack the path
+	var trace [][]int
+	found := false
+
+search:
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && bytes.Equal(a[x], b[y]) {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break search
+			}
+		}
+	}
+
+	var ops []diffOp
+	if !found {
+		for _, line := range a {
+			ops = append(ops, diffOp{'-', line})
+		}
+		for _, line := range b {
+			ops = append(ops, diffOp{'+', line})
+		}
+		return ops
+	}
+
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[k-1+d] < v[k+1+d]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		var prevX int
+		if d > 0 {
+			prevX = v[prevK+d]
+		}
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			ops = append(ops, diffOp{' ', a[x-1]})
+			x--
+			y--
+		}
+
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, diffOp{'+', b[y-1]})
+				y--
+			} else {
+				ops = append(ops, diffOp{'-', a[x-1]})
+				x--
+			}
+		}
+	}
+
+	// The ops were collected from the end
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+
+	return ops
+}
+
+// WriteUnifiedDiff writes the difference between from and to in the unified diff format
+// Nothing is written if the contents are equal
+func WriteUnifiedDiff(w io.Writer, fromName, toName string, from, to []byte) error {
+	if bytes.Equal(from, to) {
+		return nil
+	}
+
+	ops := diffLines(splitLines(from), splitLines(to))
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "--- %s\n", fromName)
+	fmt.Fprintf(bw, "+++ %s\n", toName)
+
+	// Line numbers (0 based) in from and to for each op
+	fromLine := make([]int, len(ops)+1)
+	toLine := make([]int, len(ops)+1)
+	for i, op := range ops {
+		fromLine[i+1] = fromLine[i]
+		toLine[i+1] = toLine[i]
+		if op.kind != '+' {
+			fromLine[i+1]++
+		}
+		if op.kind != '-' {
+			toLine[i+1]++
+		}
+	}
+
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		// Extend the hunk until the gap between two changes is larger than
+		// what the context lines on both sides can cover
+		start := i - DiffContextLines
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].kind != ' ' {
+				end = j + 1
+			} else if j-end >= 2*DiffContextLines {
+				break
+			}
+		}
+		stop := end + DiffContextLines
+		if stop > len(ops) {
+			stop = len(ops)
+		}
+
+		fromCount := fromLine[stop] - fromLine[start]
+		toCount := toLine[stop] - toLine[start]
+		fmt.Fprintf(bw, "@@ -%s +%s @@\n",
+			hunkRange(fromLine[start], fromCount), hunkRange(toLine[start], toCount))
+
+		for _, op := range ops[start:stop] {
+			bw.WriteByte(op.kind)
+			bw.Write(op.line)
+			if !bytes.HasSuffix(op.line, []byte{'\n'}) {
+				bw.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+
+		i = stop
+	}
+
+	return bw.Flush()
+}
+
+// hunkRange formats the start line and the line count of a hunk
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
@@ -0,0 +1,145 @@
+package gofind
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteUnifiedDiff_NoChange(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteUnifiedDiff(&buf, "a", "b", []byte("one\ntwo\n"), []byte("one\ntwo\n"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, buf.Len())
+}
+
+func TestWriteUnifiedDiff_Change(t *testing.T) {
+	from := []byte("1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n")
+	to := []byte("1\n2 two\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n15\n")
+
+	var buf bytes.Buffer
+	err := WriteUnifiedDiff(&buf, "a/f.in", "b/f.in", from, to)
+
+	expectedOutput := `--- a/f.in
++++ b/f.in
+@@ -1,5 +1,5 @@
+ 1
+-2
++2 two
+ 3
+ 4
+ 5
+@@ -11,5 +11,4 @@
+ 11
+ 12
+ 13
+-14
+ 15
+`
+	assert.NoError(t, err)
+	assert.Equal(t, expectedOutput, buf.String())
+}
+
+func TestWriteUnifiedDiff_NoNewLineAtEnd(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteUnifiedDiff(&buf, "a", "b", []byte("one\ntwo"), []byte("ONE\ntwo"))
+
+	expectedOutput := `--- a
++++ b
+@@ -1,2 +1,2 @@
+-one
++ONE
+ two
+\ No newline at end of file
+`
+	assert.NoError(t, err)
+	assert.Equal(t, expectedOutput, buf.String())
+}
+
+func TestWriteUnifiedDiff_Empty(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteUnifiedDiff(&buf, "a", "b", []byte(""), []byte("one\n"))
+
+	expectedOutput := `--- a
++++ b
+@@ -0,0 +1,1 @@
++one
+`
+	assert.NoError(t, err)
+	assert.Equal(t, expectedOutput, buf.String())
+}
+
+// lcsLength returns the length of the longest common subsequence of a and b
+func lcsLength(a, b [][]byte) int {
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if bytes.Equal(a[i], b[j]) {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] > lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	return lengths[0][0]
+}
+
+func TestDiffLines(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	randomLines := func() [][]byte {
+		lines := make([][]byte, rnd.Intn(30))
+		for i := range lines {
+			lines[i] = []byte{byte('a' + rnd.Intn(4)), '\n'}
+		}
+		return lines
+	}
+
+	for i := 0; i < 200; i++ {
+		a, b := randomLines(), randomLines()
+		ops := diffLines(a, b)
+
+		// The edit script turns a into b, with the fewest edits
+		var from, to [][]byte
+		edits := 0
+		for _, op := range ops {
+			if op.kind != '+' {
+				from = append(from, op.line)
+			}
+			if op.kind != '-' {
+				to = append(to, op.line)
+			}
+			if op.kind != ' ' {
+				edits++
+			}
+		}
+		assert.Equal(t, bytes.Join(a, nil), bytes.Join(from, nil))
+		assert.Equal(t, bytes.Join(b, nil), bytes.Join(to, nil))
+		assert.Equal(t, len(a)+len(b)-2*lcsLength(a, b), edits)
+	}
+}
+
+func TestWriteUnifiedDiff_AllLinesChanged(t *testing.T) {
+	var from, to bytes.Buffer
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&from, "line %d\n", i)
+		fmt.Fprintf(&to, "line %d\r\n", i)
+	}
+
+	// Beyond maxDiffEdits, the lines are given as deleted then inserted in a single hunk
+	var buf bytes.Buffer
+	err := WriteUnifiedDiff(&buf, "a", "b", from.Bytes(), to.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, bytes.Count(buf.Bytes(), []byte("@@ -1,20000 +1,20000 @@")))
+	assert.Equal(t, 20000, bytes.Count(buf.Bytes(), []byte("\n-line ")))
+	assert.Equal(t, 20000, bytes.Count(buf.Bytes(), []byte("\n+line ")))
+}
@@ -0,0 +1,203 @@
+package gofind
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encoding converts text between a character encoding and UTF-8
+type Encoding struct {
+	Name string
+
+	// bom is the byte order mark identifying the encoding
+	bom    []byte
+	decode func(data []byte) ([]byte, error)
+	encode func(text []byte) ([]byte, error)
+}
+
+// Supported encodings
+var (
+	UTF8 = &Encoding{
+		Name:   "utf-8",
+		bom:    []byte{0xef, 0xbb, 0xbf},
+		decode: func(data []byte) ([]byte, error) { return data, nil },
+		encode: func(text []byte) ([]byte, error) { return text, nil },
+	}
+	UTF16LE = &Encoding{
+		Name:   "utf-16le",
+		bom:    []byte{0xff, 0xfe},
+		decode: func(data []byte) ([]byte, error) { return decodeUTF16(data, false) },
+		encode: func(text []byte) ([]byte, error) { return encodeUTF16(text, false), nil },
+	}
+	UTF16BE = &Encoding{
+		Name:   "utf-16be",
+		bom:    []byte{0xfe, 0xff},
+		decode: func(data []byte) ([]byte, error) { return decodeUTF16(data, true) },
+		encode: func(text []byte) ([]byte, error) { return encodeUTF16(text, true), nil },
+	}
+	Latin1 = &Encoding{
+		Name:   "iso-8859-1",
+		decode: decodeLatin1,
+		encode: encodeLatin1,
+	}
+)
+
+// encodingNames maps the names (and aliases) of the encodings
+var encodingNames = map[string]*Encoding{
+	"utf-8":      UTF8,
+	"utf8":       UTF8,
+	"utf-16":     UTF16LE,
+	"utf-16le":   UTF16LE,
+	"utf-16be":   UTF16BE,
+	"iso-8859-1": Latin1,
+	"latin1":     Latin1,
+	"latin-1":    Latin1,
+}
+
+// LookupEncoding returns the encoding with the given name
+// Supported names are utf-8, utf-16le, utf-16be, utf-16 (little endian unless there is a BOM)
+// and iso-8859-1 (latin1)
+func LookupEncoding(name string) (*Encoding, error) {
+	enc, ok := encodingNames[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("Unknown encoding '%s'", name)
+	}
+
+	return enc, nil
+}
+
+// String returns the name of the encoding
+func (enc *Encoding) String() string {
+	return enc.Name
+}
+
+// DetectBOM returns the encoding identified by the byte order mark at the start of data, if any
+func DetectBOM(data []byte) *Encoding {
+	for _, enc := range []*Encoding{UTF8, UTF16LE, UTF16BE} {
+		if bytes.HasPrefix(data, enc.bom) {
+			return enc
+		}
+	}
+
+	return nil
+}
+
+// Text is content decoded to UTF-8, along with the details to encode it back
+type Text struct {
+	Encoding *Encoding
+	// BOM is set if the content started with a byte order mark
+	BOM bool
+}
+
+// DecodeText decodes data to UTF-8
+// The encoding is detected from the byte order mark, if there is one. Otherwise
+// enc is used, or UTF-8 if enc is nil
+func DecodeText(data []byte, enc *Encoding) ([]byte, Text, error) {
+	text := Text{Encoding: enc}
+	if bomEnc := DetectBOM(data); bomEnc != nil {
+		text = Text{Encoding: bomEnc, BOM: true}
+		data = data[len(bomEnc.bom):]
+	}
+	if text.Encoding == nil {
+		text.Encoding = UTF8
+	}
+
+	decoded, err := text.Encoding.decode(data)
+	if err != nil {
+		return nil, text, fmt.Errorf("Failed to decode as %s. err=%v", text.Encoding, err)
+	}
+
+	return decoded, text, nil
+}
+
+// Encode encodes the UTF-8 content back, with the byte order mark if the original content had one
+func (t Text) Encode(content []byte) ([]byte, error) {
+	encoded, err := t.Encoding.encode(content)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to encode as %s. err=%v", t.Encoding, err)
+	}
+
+	if t.BOM {
+		encoded = append(append([]byte{}, t.Encoding.bom...), encoded...)
+	}
+
+	return encoded, nil
+}
+
+// needsDecoding reports whether content starting with head has to be decoded,
+// given the encoding expected (UTF-8 if nil)
+func needsDecoding(head []byte, enc *Encoding) bool {
+	return DetectBOM(head) != nil || (enc != nil && enc != UTF8)
+}
+
+func decodeUTF16(data []byte, bigEndian bool) ([]byte, error) {
+	if len(data)%2 != 0 {
+		return nil, fmt.Errorf("odd number of bytes")
+	}
+
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+		} else {
+			units[i] = uint16(data[2*i+1])<<8 | uint16(data[2*i])
+		}
+	}
+
+	// Unpaired surrogates can not be encoded back, refuse them
+	text := make([]byte, 0, len(units))
+	for i := 0; i < len(units); i++ {
+		r := rune(units[i])
+		if utf16.IsSurrogate(r) {
+			if i+1 >= len(units) {
+				return nil, fmt.Errorf("unpaired surrogate at offset %d", 2*i)
+			}
+			r = utf16.DecodeRune(r, rune(units[i+1]))
+			if r == utf8.RuneError {
+				return nil, fmt.Errorf("unpaired surrogate at offset %d", 2*i)
+			}
+			i++
+		}
+		text = append(text, string(r)...)
+	}
+
+	return text, nil
+}
+
+func encodeUTF16(text []byte, bigEndian bool) []byte {
+	units := utf16.Encode([]rune(string(text)))
+	data := make([]byte, 2*len(units))
+	for i, u := range units {
+		if bigEndian {
+			data[2*i], data[2*i+1] = byte(u>>8), byte(u)
+		} else {
+			data[2*i], data[2*i+1] = byte(u), byte(u>>8)
+		}
+	}
+
+	return data
+}
+
+func decodeLatin1(data []byte) ([]byte, error) {
+	text := make([]byte, 0, len(data))
+	for _, b := range data {
+		text = append(text, string(rune(b))...)
+	}
+
+	return text, nil
+}
+
+func encodeLatin1(text []byte) ([]byte, error) {
+	data := make([]byte, 0, len(text))
+	for _, r := range string(text) {
+		if r > 0xff {
+			return nil, fmt.Errorf("character %q not in the encoding", r)
+		}
+		data = append(data, byte(r))
+	}
+
+	return data, nil
+}
@@ -0,0 +1,123 @@
+package gofind
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupEncoding(t *testing.T) {
+	enc, err := LookupEncoding("UTF-16")
+	assert.NoError(t, err)
+	assert.Equal(t, UTF16LE, enc)
+
+	enc, err = LookupEncoding("latin1")
+	assert.NoError(t, err)
+	assert.Equal(t, Latin1, enc)
+
+	_, err = LookupEncoding("ebcdic")
+	assert.Error(t, err)
+}
+
+func TestDecodeText(t *testing.T) {
+	// No BOM, UTF-8 by default
+	decoded, text, err := DecodeText([]byte("café"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "café", string(decoded))
+	assert.Equal(t, Text{Encoding: UTF8}, text)
+
+	// The BOM takes precedence over the encoding given
+	decoded, text, err = DecodeText([]byte{0xff, 0xfe, 'h', 0, 0xe9, 0}, Latin1)
+	assert.NoError(t, err)
+	assert.Equal(t, "hé", string(decoded))
+	assert.Equal(t, Text{Encoding: UTF16LE, BOM: true}, text)
+
+	decoded, text, err = DecodeText([]byte{0xfe, 0xff, 0xd8, 0x3d, 0xde, 0x00}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "😀", string(decoded))
+	assert.Equal(t, Text{Encoding: UTF16BE, BOM: true}, text)
+
+	decoded, text, err = DecodeText([]byte("caf\xe9"), Latin1)
+	assert.NoError(t, err)
+	assert.Equal(t, "café", string(decoded))
+
+	// Content that can not be encoded back is refused
+	_, _, err = DecodeText([]byte{'h', 0, 0x3d, 0xd8}, UTF16LE)
+	assert.Error(t, err)
+	_, _, err = DecodeText([]byte{'h', 0, 'i'}, UTF16LE)
+	assert.Error(t, err)
+}
+
+func TestText_Encode(t *testing.T) {
+	for _, data := range [][]byte{
+		[]byte("plain"),
+		[]byte("\xef\xbb\xbfwith BOM"),
+		[]byte{0xff, 0xfe, 'h', 0, 0xe9, 0, 0x3d, 0xd8, 0x00, 0xde},
+		[]byte{0xfe, 0xff, 0, 'h', 0, 0xe9},
+	} {
+		decoded, text, err := DecodeText(data, nil)
+		assert.NoError(t, err)
+		encoded, err := text.Encode(decoded)
+		assert.NoError(t, err)
+		assert.Equal(t, data, encoded)
+	}
+
+	encoded, err := Text{Encoding: Latin1}.Encode([]byte("café"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("caf\xe9"), encoded)
+
+	_, err = Text{Encoding: Latin1}.Encode([]byte("€"))
+	assert.Error(t, err)
+}
+
+func TestFileSearchReplaceWithOptions_Encoding(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	patterns := []SearchReplacePattern{
+		SearchReplacePattern{
+			SearchRegex:    makeRegex(t, "é+"),
+			ReplacePattern: []byte("è"),
+			Occurrences:    -1,
+		},
+	}
+
+	// UTF-16 files are detected by their BOM, and not taken as binary
+	inFilePath := filepath.Join(dir, "f.rc")
+	err = ioutil.WriteFile(inFilePath, []byte{0xff, 0xfe, 'h', 0, 0xe9, 0, 0xe9, 0}, 0644)
+	assert.NoError(t, err)
+
+	updated, err := FileSearchReplaceWithOptions(inFilePath, inFilePath, patterns, nil, FileOptions{})
+	assert.NoError(t, err)
+	assert.True(t, updated)
+	data, err := ioutil.ReadFile(inFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0xff, 0xfe, 'h', 0, 0xe8, 0}, data)
+
+	// Latin-1 files have to be told, even when large enough to be streamed
+	inFilePath = filepath.Join(dir, "f.txt")
+	err = ioutil.WriteFile(inFilePath, []byte("caf\xe9\xe9"), 0644)
+	assert.NoError(t, err)
+
+	opts := FileOptions{Encoding: Latin1, StreamThreshold: 1}
+	updated, err = FileSearchReplaceWithOptions(inFilePath, inFilePath, patterns, nil, opts)
+	assert.NoError(t, err)
+	assert.True(t, updated)
+	data, err = ioutil.ReadFile(inFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("caf\xe8"), data)
+
+	// Replacements that can not be encoded fail, the file is left as it is
+	patterns[0].ReplacePattern = []byte("€")
+	err = ioutil.WriteFile(inFilePath, []byte("caf\xe9"), 0644)
+	assert.NoError(t, err)
+	_, err = FileSearchReplaceWithOptions(inFilePath, inFilePath, patterns, nil, opts)
+	assert.Error(t, err)
+	data, err = ioutil.ReadFile(inFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("caf\xe9"), data)
+}
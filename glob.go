@@ -0,0 +1,124 @@
+package gofind
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Glob is a shell style file name pattern, matched against a slash separated relative path
+//
+//	**      matches any sequence of characters, including '/'
+//	**/     matches zero or more directories
+//	*       matches any sequence of characters except '/'
+//	?       matches any single character except '/'
+//	[abc]   matches one of the characters in the class; [!abc] negates the class
+//	{a,b}   matches one of the alternatives
+//
+// A pattern without a '/' (other than a trailing one) is matched against the last
+// element of the path only. A pattern ending with '/' matches directories only
+// A leading '/' anchors the pattern to the root
+type Glob struct {
+	Pattern string
+
+	re       *regexp.Regexp
+	baseName bool
+}
+
+// CompileGlob parses a glob pattern
+func CompileGlob(pattern string) (*Glob, error) {
+	glob := strings.TrimPrefix(pattern, "/")
+	body, err := globToRegexp(glob)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid glob '%s'. err=%v", pattern, err)
+	}
+
+	re, err := regexp.Compile("^" + body + "$")
+	if err != nil {
+		return nil, fmt.Errorf("Invalid glob '%s'. err=%v", pattern, err)
+	}
+
+	return &Glob{
+		Pattern:  pattern,
+		re:       re,
+		baseName: !strings.Contains(strings.TrimSuffix(pattern, "/"), "/"),
+	}, nil
+}
+
+// Match tests the glob on a path relative to the root of the search
+func (g *Glob) Match(relPath string, isDir bool) bool {
+	p := filepath.ToSlash(relPath)
+	if g.baseName {
+		p = path.Base(p)
+	}
+
+	if g.re.MatchString(p) {
+		return true
+	}
+
+	return isDir && g.re.MatchString(p+"/")
+}
+
+// String returns the glob pattern
+func (g *Glob) String() string {
+	return g.Pattern
+}
+
+// globToRegexp converts a glob pattern to an equivalent (unanchored) regular expression
+func globToRegexp(glob string) (string, error) {
+	var re strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					i++
+					re.WriteString("(?:.*/)?")
+				} else {
+					re.WriteString(".*")
+				}
+			} else {
+				re.WriteString("[^/]*")
+			}
+
+		case '?':
+			re.WriteString("[^/]")
+
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return "", fmt.Errorf("missing ']'")
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + class + "]")
+			i += end + 1
+
+		case '{':
+			end := strings.IndexByte(glob[i+1:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("missing '}'")
+			}
+			var alternatives []string
+			for _, alt := range strings.Split(glob[i+1:i+1+end], ",") {
+				altRe, err := globToRegexp(alt)
+				if err != nil {
+					return "", err
+				}
+				alternatives = append(alternatives, altRe)
+			}
+			re.WriteString("(?:" + strings.Join(alternatives, "|") + ")")
+			i += end + 1
+
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	return re.String(), nil
+}
@@ -0,0 +1,80 @@
+package gofind
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func makeGlob(t *testing.T, pattern string) *Glob {
+	g, err := CompileGlob(pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return g
+}
+
+func TestGlob_Match(t *testing.T) {
+	tests := []struct {
+		glob    string
+		path    string
+		isDir   bool
+		matched bool
+	}{
+		{"*.go", "main.go", false, true},
+		{"*.go", "cmd/gofind/main.go", false, true},
+		{"*.go", "main.go.orig", false, false},
+		{"**/*.go", "main.go", false, true},
+		{"**/*.go", "cmd/gofind/main.go", false, true},
+		{"cmd/*.go", "cmd/gofind/main.go", false, false},
+		{"cmd/**", "cmd/gofind/main.go", false, true},
+		{"vendor/**", "vendor", true, true},
+		{"vendor/**", "vendor", false, false},
+		{"/vendor/**", "vendor/x/y.go", false, true},
+		{"build/", "build", true, true},
+		{"build/", "src/build", true, true},
+		{"/build", "src/build", false, false},
+		{"build/", "build", false, false},
+		{"f?.in", "f1.in", false, true},
+		{"f?.in", "f10.in", false, false},
+		{"f[0-2].in", "f2.in", false, true},
+		{"f[!0-2].in", "f2.in", false, false},
+		{"*.{in,ex}", "subdir/e1.ex", false, true},
+		{"*.{in,ex}", "subdir/e1.go", false, false},
+		{"a.b", "axb", false, false},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.matched, makeGlob(t, test.glob).Match(test.path, test.isDir), "%s - %s", test.glob, test.path)
+	}
+}
+
+func TestCompileGlob_Invalid(t *testing.T) {
+	_, err := CompileGlob("f[0-2.in")
+	assert.Error(t, err)
+
+	_, err = CompileGlob("*.{in,ex")
+	assert.Error(t, err)
+}
+
+func TestFilter_TestPath(t *testing.T) {
+	filter := Filter{
+		Include:      []*regexp.Regexp{makeRegex(t, `\.ex$`)},
+		IncludeGlobs: []*Glob{makeGlob(t, "**/*.in")},
+		ExcludeGlobs: []*Glob{makeGlob(t, "vendor/**")},
+	}
+
+	canSelect, _, _ := filter.TestPath("./root/f1.in", "f1.in", false)
+	assert.True(t, canSelect)
+
+	canSelect, _, _ = filter.TestPath("./root/e1.ex", "e1.ex", false)
+	assert.True(t, canSelect)
+
+	canSelect, _, _ = filter.TestPath("./root/f1.go", "f1.go", false)
+	assert.False(t, canSelect)
+
+	_, _, exclude := filter.TestPath("./root/vendor", "vendor", true)
+	assert.True(t, exclude)
+}
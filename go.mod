@@ -4,5 +4,7 @@ go 1.13
 
 require (
 	github.com/ghodss/yaml v1.0.0
+	github.com/pelletier/go-toml v1.9.5
 	github.com/stretchr/testify v1.4.0
+	gopkg.in/yaml.v3 v3.0.1
 )
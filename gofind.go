@@ -2,28 +2,38 @@ package gofind
 
 import (
 	"bytes"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sync"
+	"unicode"
+	"unicode/utf8"
 )
 
 // Filter stores the inclusion and exclusion patterns
-//
 type Filter struct {
 	Include []*regexp.Regexp
 	Exclude []*regexp.Regexp
+
+	// Globs are only used by TestPath, on the path relative to the root of the search
+	IncludeGlobs []*Glob
+	ExcludeGlobs []*Glob
 }
 
 // TestFilters applies the inclusion and exclusion tests on the given data
 // Returns true if:
-//		there are no exclusion patterns, or none of the exclusion tests pass
-//  	And
-//  	there are no inclusion patterns, or one of the inclusion patterns pass
+//
+//	there are no exclusion patterns, or none of the exclusion tests pass
+//	And
+//	there are no inclusion patterns, or one of the inclusion patterns pass
+//
 // Returns false if:
-//		At least one of the exclusion patterns pass or
-//		All of the inclusion patterns fails
+//
+//	At least one of the exclusion patterns pass or
+//	All of the inclusion patterns fails
 func (f *Filter) TestFilters(data []byte) (canSelect, include, exclude bool) {
 	include = true
 	exclude = false
@@ -54,79 +64,433 @@ func (f *Filter) TestFilters(data []byte) (canSelect, include, exclude bool) {
 	return !exclude && include, include, exclude
 }
 
+// TestPath applies the inclusion and exclusion tests on a path
+// The regular expressions are tested on path and the globs on relPath, the path
+// relative to the root of the search. An inclusion test passes if any one of the
+// regular expressions or globs match, the exclusion test works the same way
+func (f *Filter) TestPath(path, relPath string, isDir bool) (canSelect, include, exclude bool) {
+	_, include, exclude = f.TestFilters([]byte(path))
+
+	// Inclusion is decided by the regular expressions and globs together
+	if len(f.IncludeGlobs) > 0 && (len(f.Include) == 0 || !include) {
+		include = false
+		for _, glob := range f.IncludeGlobs {
+			if glob.Match(relPath, isDir) {
+				include = true
+				break
+			}
+		}
+	}
+
+	if !exclude {
+		for _, glob := range f.ExcludeGlobs {
+			if glob.Match(relPath, isDir) {
+				exclude = true
+				break
+			}
+		}
+	}
+
+	return !exclude && include, include, exclude
+}
+
 // SearchReplacePattern stores the pattern to be searched for and replaced
 type SearchReplacePattern struct {
 	SearchRegex    *regexp.Regexp
 	ReplacePattern []byte
 	Occurrences    int
 	Filter         *Filter
+
+	// Binary applies the pattern on binary files too, even if FileOptions.Binary is not set
+	Binary bool
+
+	// ReplaceFunc, if not nil, computes the replacement of each match instead of ReplacePattern
+	ReplaceFunc func(m *Submatch) []byte
+
+	// Counter, if not nil, gives the values of VarCounter in ReplacePattern
+	// The same Counter can be shared by several patterns
+	Counter *Counter
+
+	// PreserveCase adapts the case of the replacement to the case of each match:
+	// all upper case, all lower case or capitalized (see MatchCase)
+	// SearchRegex is expected to be case-insensitive, e.g. with the (?i) flag
+	PreserveCase bool
+}
+
+// Submatch is a match passed to a ReplaceFunc
+type Submatch struct {
+	// Groups holds the text of the match, followed by the text of each capture group
+	// A group that did not take part in the match is nil
+	Groups [][]byte
+	// Index and Occurrence are the values of VarIndex and VarOccurrence for the match
+	Index, Occurrence int
+}
+
+// replaces reports whether the pattern has a replacement, as opposed to being searched only
+func (p *SearchReplacePattern) replaces() bool {
+	return p.ReplacePattern != nil || p.ReplaceFunc != nil
 }
 
+// Decision is the answer to a confirmation request for a match
+type Decision int
+
+// Possible answers to a confirmation request
+const (
+	// ReplaceYes replaces the match
+	ReplaceYes Decision = iota
+	// ReplaceNo leaves the match unchanged
+	ReplaceNo
+	// ReplaceAll replaces the match and all the remaining matches without confirmation
+	ReplaceAll
+	// ReplaceQuit leaves the match and all the remaining matches unchanged
+	ReplaceQuit
+)
+
+// Match describes a match selected for replacement
+type Match struct {
+	// Path of the file being processed, if any
+	Path string
+	// Data is the content being searched
+	Data []byte
+	// Start and End are the location of the match in Data
+	Start, End int
+	// Replacement is the text that would replace the match
+	Replacement []byte
+}
+
+// ConfirmFunc is called for every match that passes the filters of the pattern
+// The match is replaced according to the returned decision
+type ConfirmFunc func(m *Match) Decision
+
 // SearchReplace searches the inData for the given patterns
 func SearchReplace(inData []byte, patterns []SearchReplacePattern) ([]byte, error) {
+	return SearchReplaceConfirm(inData, patterns, nil)
+}
+
+// SearchReplaceConfirm searches the inData for the given patterns and asks confirm,
+// if not nil, whether each match has to be replaced
+func SearchReplaceConfirm(inData []byte, patterns []SearchReplacePattern, confirm ConfirmFunc) ([]byte, error) {
+	return searchReplace(inData, patterns, confirm, nil), nil
+}
+
+// searchReplace applies the patterns and, if stats is not nil, counts the matches of each pattern
+func searchReplace(inData []byte, patterns []SearchReplacePattern, confirm ConfirmFunc, stats []PatternStats) []byte {
 	replaced := inData
+
+	// The replacements are only counted if needed for VarIndex
+	index := 0
+	countIndex := false
+	for i := range patterns {
+		countIndex = countIndex || patterns[i].ReplaceFunc != nil || hasVars(patterns[i].ReplacePattern)
+	}
 	for i := range patterns {
-		if patterns[i].ReplacePattern == nil {
+		if !patterns[i].replaces() {
 			continue
 		}
 
 		// If all occurrences need to be replaced, with no filters to be applied
 		// for each replacement, replace everything in one go
-		if patterns[i].Occurrences < 0 && (patterns[i].Filter == nil || len(patterns[i].Filter.Include)+len(patterns[i].Filter.Exclude) == 0) {
+		if confirm == nil && patterns[i].ReplaceFunc == nil && !patterns[i].PreserveCase && !hasVars(patterns[i].ReplacePattern) && patterns[i].Occurrences < 0 && (patterns[i].Filter == nil || len(patterns[i].Filter.Include)+len(patterns[i].Filter.Exclude) == 0) {
+			if stats != nil || countIndex {
+				n := len(patterns[i].SearchRegex.FindAllIndex(replaced, -1))
+				if stats != nil {
+					stats[i].Found += n
+					stats[i].Replaced += n
+				}
+				index += n
+			}
 			replaced = patterns[i].SearchRegex.ReplaceAll(replaced, patterns[i].ReplacePattern)
 			continue
 		}
 
-		// TODO: Optimize
-		count := patterns[i].Occurrences
-		var segs [][]byte // Segments of data
-		searchBuf := replaced
-		for {
-			// Loop exit condition on count
-			// If occurrences is provided, use that in the loop exit condition
-			// Otherwise loop until all matches are processed
-			if count == 0 {
-				break
-			}
-			if count > 0 {
-				count--
-			}
+		r := newReplacer(&patterns[i], confirm, &index)
+		replaced, _ = r.replace(nil, replaced, 0, len(replaced))
+		if stats != nil {
+			r.addStats(&stats[i])
+		}
+		if r.quit {
+			break
+		}
+		confirm = r.confirm
+	}
 
-			loc := patterns[i].SearchRegex.FindIndex(searchBuf)
-			if loc == nil { // No match
-				break
-			}
+	return replaced
+}
 
-			if loc[0] != 0 {
-				seg := searchBuf[0:loc[0]]
-				segs = append(segs, seg)
-			}
+// replacer applies a pattern one match at a time
+// The state is kept across calls, so that the data can be processed in chunks
+type replacer struct {
+	pattern *SearchReplacePattern
+	confirm ConfirmFunc
+	index   *int // Replacements made in the content, shared by the replacers of all the patterns
 
-			s := searchBuf[loc[0]:loc[1]]
-			if len(s) == 0 { // Some regex trouble, break out anyway
-				log.Print("Warning: RegExp: '", patterns[i].SearchRegex.String(), "' causing ZERO length match; please verify RegExp")
-				break
-			}
+	count   int  // Remaining occurrences to be replaced, negative for all
+	done    bool // No more matches need to be processed
+	quit    bool // The user chose to leave the remaining matches unchanged
+	changed bool // At least one match was replaced
+
+	found, replaced, filtered int
+
+	// afterRegex matches one character followed by the pattern, to search with the text before as context
+	afterRegex *regexp.Regexp
+}
+
+// addStats adds the matches counted so far to stats
+func (r *replacer) addStats(stats *PatternStats) {
+	stats.Found += r.found
+	stats.Replaced += r.replaced
+	stats.Filtered += r.filtered
+}
+
+func newReplacer(pattern *SearchReplacePattern, confirm ConfirmFunc, index *int) *replacer {
+	return &replacer{
+		pattern: pattern,
+		confirm: confirm,
+		index:   index,
+		count:   pattern.Occurrences,
+	}
+}
+
+// replace appends data from start to out, replacing the matches that start before limit
+// The data before start is only the context of the matches, e.g. for ^ or \b, and is not appended
+// Returns the updated out and the number of bytes of data consumed, including the context
+// Unless limit is len(data), data following the last match before limit is left
+// for the next call, in case it is part of a match that continues in the next chunk
+func (r *replacer) replace(out, data []byte, start, limit int) ([]byte, int) {
+	searchBuf := data[start:]
+	for !r.done {
+		// Loop exit condition on count
+		// If occurrences is provided, use that in the loop exit condition
+		// Otherwise loop until all matches are processed
+		if r.count == 0 {
+			r.done = true
+			break
+		}
+
+		offset := len(data) - len(searchBuf)
+		loc := r.find(data, offset)
+		if loc == nil || offset+loc[0] >= limit { // No match
+			break
+		}
+
+		s := searchBuf[loc[0]:loc[1]]
+		if len(s) == 0 { // Some regex trouble, break out anyway
+			log.Print("Warning: RegExp: '", r.pattern.SearchRegex.String(), "' causing ZERO length match; please verify RegExp")
+			r.done = true
+			break
+		}
+
+		if r.count > 0 {
+			r.count--
+		}
+		r.found++
 
-			shouldReplace := true
-			if patterns[i].Filter != nil {
-				shouldReplace, _, _ = patterns[i].Filter.TestFilters(s)
+		out = append(out, searchBuf[0:loc[0]]...)
+
+		shouldReplace := true
+		if r.pattern.Filter != nil {
+			shouldReplace, _, _ = r.pattern.Filter.TestFilters(s)
+		}
+		if !shouldReplace {
+			r.filtered++
+		}
+		rs := s
+		if shouldReplace {
+			// A value of the counter is taken only for a match that is replaced
+			rs = r.replacement(s, searchBuf, loc, r.confirm == nil)
+		}
+		if shouldReplace && r.confirm != nil {
+			switch r.confirm(&Match{Data: data, Start: offset + loc[0], End: offset + loc[1], Replacement: rs}) {
+			case ReplaceNo:
+				shouldReplace = false
+			case ReplaceAll:
+				r.confirm = nil
+			case ReplaceQuit:
+				shouldReplace = false
+				r.quit = true
+				r.done = true
 			}
-			r := s
-			if shouldReplace {
-				r = patterns[i].SearchRegex.ReplaceAll(s, patterns[i].ReplacePattern)
+			// The replacement shown only peeked at the counter
+			if shouldReplace && usesCounter(r.pattern.ReplacePattern, r.pattern.Counter) {
+				rs = r.replacement(s, searchBuf, loc, true)
 			}
-			segs = append(segs, r)
-			searchBuf = searchBuf[loc[1]:]
 		}
-		if len(searchBuf) > 0 {
-			segs = append(segs, searchBuf)
+		if !shouldReplace {
+			rs = s
+		} else {
+			r.replaced++
+			*r.index++
+		}
+		if !bytes.Equal(rs, s) {
+			r.changed = true
+		}
+		out = append(out, rs...)
+		searchBuf = searchBuf[loc[1]:]
+	}
+
+	// Keep the data after limit, unless there is nothing more to replace
+	consumed := len(data)
+	if !r.done && limit < len(data) {
+		consumed = len(data) - len(searchBuf)
+		if consumed < limit {
+			consumed = limit
+		}
+	}
+	out = append(out, data[len(data)-len(searchBuf):consumed]...)
+
+	return out, consumed
+}
+
+// find returns the location of the first match in data[offset:], relative to it
+// The text before offset is taken into account by the anchors, as if the search went on from there
+func (r *replacer) find(data []byte, offset int) []int {
+	if offset == 0 {
+		return r.pattern.SearchRegex.FindSubmatchIndex(data)
+	}
+
+	if r.afterRegex == nil {
+		r.afterRegex = regexp.MustCompile(`(?s:.)(?:` + r.pattern.SearchRegex.String() + `)`)
+	}
+
+	// Search from the character before offset, which the added '.' matches
+	_, width := utf8.DecodeLastRune(data[:offset])
+	base := offset - width
+	loc := r.afterRegex.FindSubmatchIndex(data[base:])
+	if loc == nil {
+		return nil
+	}
+
+	_, width = utf8.DecodeRune(data[base+loc[0]:])
+	loc[0] += width
+	for i := range loc {
+		if loc[i] >= 0 {
+			loc[i] += base - offset
+		}
+	}
+
+	return loc
+}
+
+// replacement returns the replacement of the match s, located in searchBuf by loc
+// take is passed on to expandVars
+func (r *replacer) replacement(s, searchBuf []byte, loc []int, take bool) []byte {
+	var rs []byte
+	if r.pattern.ReplaceFunc != nil {
+		rs = r.pattern.ReplaceFunc(&Submatch{Groups: submatches(searchBuf, loc), Index: *r.index + 1, Occurrence: r.found})
+	} else {
+		replacePattern := r.pattern.ReplacePattern
+		if hasVars(replacePattern) {
+			replacePattern = expandVars(replacePattern, *r.index+1, r.found, r.pattern.Counter, take)
+		}
+		rs = r.pattern.SearchRegex.ReplaceAll(s, replacePattern)
+	}
+	if r.pattern.PreserveCase {
+		rs = MatchCase(s, rs)
+	}
+
+	return rs
+}
+
+// submatches returns the text of the match and capture groups located in data by loc
+func submatches(data []byte, loc []int) [][]byte {
+	groups := make([][]byte, len(loc)/2)
+	for i := range groups {
+		if loc[2*i] >= 0 {
+			groups[i] = data[loc[2*i]:loc[2*i+1]]
+		}
+	}
+
+	return groups
+}
+
+// MatchCase returns replacement with the case of match: in upper case if match is in
+// upper case (with more than one letter), in lower case if match is in lower case and
+// with its first letter in upper case if match starts with one. Otherwise replacement
+// is returned as it is
+func MatchCase(match, replacement []byte) []byte {
+	var letters, upper, lower int
+	for _, r := range string(match) {
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			} else if unicode.IsLower(r) {
+				lower++
+			}
 		}
+	}
+
+	switch {
+	case letters == 0:
+		return replacement
+	case upper == letters && letters > 1:
+		return bytes.ToUpper(replacement)
+	case lower == letters:
+		return bytes.ToLower(replacement)
+	}
+
+	first, _ := utf8.DecodeRune(match)
+	if !unicode.IsUpper(first) {
+		return replacement
+	}
 
-		replaced = bytes.Join(segs, []byte{})
+	r, size := utf8.DecodeRune(replacement)
+	if size == 0 {
+		return replacement
 	}
+	return append([]byte(string(unicode.ToUpper(r))), replacement[size:]...)
+}
+
+// FileOptions controls how FileSearchReplaceWithOptions handles the updated content
+type FileOptions struct {
+	// DryRun computes the updated content without writing the output file
+	DryRun bool
+
+	// Diff, if not nil, receives a unified diff for every file with a content update
+	// The diff of a file is written with a single Write call, so a writer safe for
+	// concurrent use (see NewSyncWriter) can be shared by concurrent calls
+	Diff io.Writer
+
+	// Confirm, if not nil, is asked whether each match has to be replaced
+	Confirm ConfirmFunc
+
+	// StreamThreshold, if positive, is the file size above which the file is processed
+	// as a stream (see SearchReplaceStream) instead of being loaded in memory
+	// Diff and Confirm are not supported for such files
+	StreamThreshold int64
 
-	return replaced, nil
+	// MaxMatchSpan is the length of the longest match expected when processing a stream
+	MaxMatchSpan int
+
+	// Journal, if not nil, records the original content of every file written
+	Journal *Journal
+
+	// PreserveTimestamps copies the modification time of the input file on the output file
+	// The permission bits are always copied
+	PreserveTimestamps bool
+
+	// Report, if not nil, is called with the outcome of processing the file
+	Report func(result *FileResult)
+
+	// SkipPatternStats leaves out the statistics of the patterns from the results given
+	// to Report, sparing the counting of the matches when only the status is needed
+	SkipPatternStats bool
+
+	// Binary applies all the patterns on binary files
+	// By default only the patterns with the Binary flag apply, and if there is none the file is skipped
+	Binary bool
+
+	// Encoding is the character encoding of the file, UTF-8 if nil
+	// A byte order mark at the start of the file takes precedence. The content is
+	// decoded to UTF-8 before applying the patterns, and encoded back when written
+	// Files to be decoded are always loaded in memory, whatever StreamThreshold is
+	Encoding *Encoding
+
+	// LineEnding, unless LineEndingPreserve, converts all the line endings of the
+	// (non binary) files processed, even if no pattern matches. Such files are always
+	// loaded in memory. Either way, line endings in the replacement text are converted
+	// to the line ending of the file (the one used by most lines, if it is not normalized)
+	LineEnding LineEnding
 }
 
 // FileSearchReplace searches the input file for the patters and updates
@@ -134,23 +498,108 @@ func SearchReplace(inData []byte, patterns []SearchReplacePattern) ([]byte, erro
 // Returns true if there is any content update (output file is written)
 // Returns false if there is no content update
 func FileSearchReplace(inFilePath, outFilePath string, patterns []SearchReplacePattern, filter *Filter) (bool, error) {
-	fileContent, err := ioutil.ReadFile(inFilePath)
+	return FileSearchReplaceWithOptions(inFilePath, outFilePath, patterns, filter, FileOptions{})
+}
+
+// FileSearchReplaceWithOptions is FileSearchReplace with additional control over the output
+// In dry-run mode, returns true if the output file would have been written
+// It is safe to call concurrently for different output files, provided opts.Diff,
+// opts.Confirm and opts.Report are safe for concurrent use
+func FileSearchReplaceWithOptions(inFilePath, outFilePath string, patterns []SearchReplacePattern, filter *Filter, opts FileOptions) (bool, error) {
+	result := FileResult{
+		InputPath:  inFilePath,
+		OutputPath: outFilePath,
+		Status:     StatusNoChange,
+	}
+
+	updated, err := fileSearchReplace(inFilePath, outFilePath, patterns, filter, opts, &result)
+
+	if opts.Report != nil {
+		if err != nil {
+			result.Status = StatusError
+			result.Error = err.Error()
+		} else if updated {
+			result.Status = StatusUpdated
+		}
+		opts.Report(&result)
+	}
+
+	return updated, err
+}
+
+// fileSearchReplace processes a file and fills in the details in result
+func fileSearchReplace(inFilePath, outFilePath string, patterns []SearchReplacePattern, filter *Filter, opts FileOptions, result *FileResult) (bool, error) {
+	inInfo, err := os.Stat(inFilePath)
 	if err != nil {
 		log.Printf("Error processing file %s. err=%v", inFilePath, err)
 		return false, err
 	}
 
+	stream := opts.StreamThreshold > 0 && opts.Confirm == nil && inInfo.Size() > opts.StreamThreshold
+
+	var fileContent []byte
+	if stream {
+		fileContent, err = readFileHead(inFilePath)
+		// Files to be decoded or normalized are loaded in memory
+		if err == nil && (needsDecoding(fileContent, opts.Encoding) || opts.LineEnding != LineEndingPreserve) {
+			stream = false
+		}
+	}
+	var text Text
+	if !stream && err == nil {
+		fileContent, err = ioutil.ReadFile(inFilePath)
+		if err == nil {
+			fileContent, text, err = DecodeText(fileContent, opts.Encoding)
+		}
+	}
+	if err != nil {
+		log.Printf("Error processing file %s. err=%v", inFilePath, err)
+		return false, err
+	}
+	binary := IsBinary(fileContent)
+
+	// Only the patterns meant for binary content apply on binary files, unless asked otherwise
+	if binary && !opts.Binary {
+		if patterns = binaryPatterns(patterns); patterns == nil {
+			log.Printf("%s [Binary, Skipped]", inFilePath)
+			result.Status = StatusBinary
+			return false, nil
+		}
+	}
+
+	// Replacement text uses the line ending of the file, unless the file is normalized
+	if !binary {
+		le := opts.LineEnding
+		if le == LineEndingPreserve {
+			le = DetectLineEnding(fileContent)
+		}
+		patterns = lineEndingPatterns(patterns, le)
+	}
+
+	if stream {
+		return fileSearchReplaceStreamLog(inFilePath, outFilePath, patterns, filter, opts, result)
+	}
+
 	// If file level filters are specified, test them and skip file accordingly
 	if filter != nil {
 		if bPass, _, _ := filter.TestFilters(fileContent); !bPass {
+			result.Status = StatusFiltered
 			return false, nil
 		}
 	}
 
-	replaced, err := SearchReplace(fileContent, patterns)
-	if err != nil {
-		log.Printf("SearchReplace failed for file %s. err=%v", inFilePath, err)
-		return false, err
+	var confirm ConfirmFunc
+	if opts.Confirm != nil {
+		confirm = func(m *Match) Decision {
+			m.Path = inFilePath
+			return opts.Confirm(m)
+		}
+	}
+
+	result.Patterns = patternStatsFor(patterns, opts)
+	replaced := searchReplace(fileContent, patterns, confirm, result.Patterns)
+	if !binary {
+		replaced = ConvertLineEndings(replaced, opts.LineEnding)
 	}
 
 	if bytes.Equal(replaced, fileContent) {
@@ -158,6 +607,20 @@ func FileSearchReplace(inFilePath, outFilePath string, patterns []SearchReplaceP
 		return false, nil
 	}
 
+	if opts.Diff != nil {
+		var diff bytes.Buffer
+		WriteUnifiedDiff(&diff, inFilePath, outFilePath, fileContent, replaced)
+		if _, err = opts.Diff.Write(diff.Bytes()); err != nil {
+			log.Printf("%s - Failed to write diff, err=%v", inFilePath, err)
+			return false, err
+		}
+	}
+
+	if opts.DryRun {
+		log.Printf("%s - Would update - %s", inFilePath, outFilePath)
+		return true, nil
+	}
+
 	if inFilePath != outFilePath {
 		outputDir := filepath.Dir(outFilePath)
 		err = os.MkdirAll(outputDir, os.ModePerm)
@@ -166,12 +629,62 @@ func FileSearchReplace(inFilePath, outFilePath string, patterns []SearchReplaceP
 			return false, err
 		}
 	}
-	err = ioutil.WriteFile(outFilePath, replaced, 0777)
+	output, err := text.Encode(replaced)
+	if err != nil {
+		log.Printf("%s - Failed to encode the updated content, err=%v", inFilePath, err)
+		return false, err
+	}
+	if opts.Journal != nil {
+		if err = opts.Journal.Record(outFilePath, HashBytes(output)); err != nil {
+			log.Printf("%s - Failed to record %s in the journal, err=%v", inFilePath, outFilePath, err)
+			return false, err
+		}
+	}
+	err = writeFileAtomic(outFilePath, output, inInfo, opts.PreserveTimestamps)
 	if err != nil {
 		log.Printf("%s - Failed to write to %s, err=%v", inFilePath, outFilePath, err)
-	} else {
-		log.Printf("%s - Updated - %s", inFilePath, outFilePath)
+		return false, err
 	}
+	log.Printf("%s - Updated - %s", inFilePath, outFilePath)
 
 	return true, nil
 }
+
+// fileSearchReplaceStreamLog processes a large file as a stream and logs the result
+func fileSearchReplaceStreamLog(inFilePath, outFilePath string, patterns []SearchReplacePattern, filter *Filter, opts FileOptions, result *FileResult) (bool, error) {
+	updated, err := fileSearchReplaceStream(inFilePath, outFilePath, patterns, filter, opts, result)
+	switch {
+	case err != nil:
+		log.Printf("%s - Failed to process as a stream, err=%v", inFilePath, err)
+	case !updated:
+		log.Printf("%s [No Change]", inFilePath)
+	case opts.DryRun:
+		if opts.Diff != nil {
+			log.Printf("%s - Diff not available for large files", inFilePath)
+		}
+		log.Printf("%s - Would update - %s", inFilePath, outFilePath)
+	default:
+		log.Printf("%s - Updated - %s", inFilePath, outFilePath)
+	}
+
+	return updated, err
+}
+
+// syncWriter serializes the writes to the underlying writer
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewSyncWriter returns a writer that is safe for concurrent use
+// Each Write call is passed on to w as a whole
+func NewSyncWriter(w io.Writer) io.Writer {
+	return &syncWriter{w: w}
+}
+
+func (sw *syncWriter) Write(p []byte) (int, error) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	return sw.w.Write(p)
+}
@@ -1,6 +1,10 @@
 package gofind
 
 import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"regexp"
 	"testing"
 
@@ -153,3 +157,225 @@ func TestSearchReplace_ReplaceOneOccurrence(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, expectedOutput, replaced)
 }
+
+func TestSearchReplace_ReplaceFunc(t *testing.T) {
+	testData := []byte("a=1 b= c=3")
+
+	var groups [][]string
+	patterns := []SearchReplacePattern{
+		SearchReplacePattern{
+			SearchRegex: makeRegex(t, `(\w)=(\d)?`),
+			Occurrences: 2,
+			ReplaceFunc: func(m *Submatch) []byte {
+				var g []string
+				for _, group := range m.Groups {
+					if group == nil {
+						g = append(g, "<nil>")
+					} else {
+						g = append(g, string(group))
+					}
+				}
+				groups = append(groups, g)
+				return bytes.ToUpper(m.Groups[1])
+			},
+		},
+	}
+
+	replaced, err := SearchReplace(testData, patterns)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("A B c=3"), replaced)
+	assert.Equal(t, [][]string{{"a=1", "a", "1"}, {"b=", "b", "<nil>"}}, groups)
+}
+
+func TestMatchCase(t *testing.T) {
+	assert.Equal(t, "GADGET", string(MatchCase([]byte("WIDGET"), []byte("gadget"))))
+	assert.Equal(t, "gadget", string(MatchCase([]byte("widget"), []byte("Gadget"))))
+	assert.Equal(t, "Gadget", string(MatchCase([]byte("Widget"), []byte("gadget"))))
+	assert.Equal(t, "Gadget", string(MatchCase([]byte("W"), []byte("gadget"))))
+	assert.Equal(t, "ÉTé", string(MatchCase([]byte("Wi"), []byte("éTé"))))
+	assert.Equal(t, "gadget", string(MatchCase([]byte("wIDGET"), []byte("gadget"))))
+	assert.Equal(t, "gadget", string(MatchCase([]byte("42"), []byte("gadget"))))
+}
+
+func TestSearchReplace_PreserveCase(t *testing.T) {
+	testData := []byte("widget Widget WIDGET widgetId getWidget")
+
+	patterns := []SearchReplacePattern{
+		SearchReplacePattern{
+			SearchRegex:    makeRegex(t, "(?i)widget"),
+			ReplacePattern: []byte("gadget"),
+			Occurrences:    -1,
+			PreserveCase:   true,
+		},
+	}
+
+	replaced, err := SearchReplace(testData, patterns)
+	assert.NoError(t, err)
+	assert.Equal(t, "gadget Gadget GADGET gadgetId getGadget", string(replaced))
+}
+
+func TestFileSearchReplaceWithOptions_DryRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	inFilePath := filepath.Join(dir, "f.in")
+	outFilePath := filepath.Join(dir, "out", "f.in")
+	err = ioutil.WriteFile(inFilePath, []byte("one\ntwo\n"), 0644)
+	assert.NoError(t, err)
+
+	patterns := []SearchReplacePattern{
+		SearchReplacePattern{
+			SearchRegex:    makeRegex(t, "one"),
+			ReplacePattern: []byte("ONE"),
+			Occurrences:    -1,
+		},
+	}
+
+	var diff bytes.Buffer
+	updated, err := FileSearchReplaceWithOptions(inFilePath, outFilePath, patterns, nil, FileOptions{DryRun: true, Diff: &diff})
+	assert.NoError(t, err)
+	assert.True(t, updated)
+	assert.Contains(t, diff.String(), "-one\n+ONE\n two\n")
+
+	_, err = os.Stat(filepath.Join(dir, "out"))
+	assert.True(t, os.IsNotExist(err))
+
+	content, err := ioutil.ReadFile(inFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("one\ntwo\n"), content)
+}
+
+func TestSearchReplaceConfirm(t *testing.T) {
+	testData := []byte("one two one two one two one")
+
+	patterns := []SearchReplacePattern{
+		SearchReplacePattern{
+			SearchRegex:    makeRegex(t, "one"),
+			ReplacePattern: []byte("ONE"),
+			Occurrences:    -1,
+		},
+		SearchReplacePattern{
+			SearchRegex:    makeRegex(t, "two"),
+			ReplacePattern: []byte("TWO"),
+			Occurrences:    -1,
+		},
+	}
+
+	decisions := []Decision{ReplaceNo, ReplaceYes, ReplaceNo, ReplaceAll}
+	var matches []string
+	replaced, err := SearchReplaceConfirm(testData, patterns, func(m *Match) Decision {
+		matches = append(matches, string(m.Data[m.Start:m.End])+"->"+string(m.Replacement))
+		d := decisions[0]
+		decisions = decisions[1:]
+		return d
+	})
+
+	assert.NoError(t, err)
+	// 'all' applies to the rest of the matches of all the patterns
+	assert.Equal(t, []byte("one TWO ONE TWO one TWO ONE"), replaced)
+	assert.Equal(t, []string{"one->ONE", "one->ONE", "one->ONE", "one->ONE"}, matches)
+}
+
+func TestSearchReplaceConfirm_Quit(t *testing.T) {
+	testData := []byte("one two one two")
+
+	patterns := []SearchReplacePattern{
+		SearchReplacePattern{
+			SearchRegex:    makeRegex(t, "one"),
+			ReplacePattern: []byte("ONE"),
+			Occurrences:    -1,
+		},
+		SearchReplacePattern{
+			SearchRegex:    makeRegex(t, "two"),
+			ReplacePattern: []byte("TWO"),
+			Occurrences:    -1,
+		},
+	}
+
+	decisions := []Decision{ReplaceYes, ReplaceQuit}
+	replaced, err := SearchReplaceConfirm(testData, patterns, func(m *Match) Decision {
+		d := decisions[0]
+		decisions = decisions[1:]
+		return d
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("ONE two one two"), replaced)
+}
+
+func TestFileSearchReplaceWithOptions_Report(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	inFilePath := filepath.Join(dir, "f.in")
+	err = ioutil.WriteFile(inFilePath, []byte("one two one two one"), 0644)
+	assert.NoError(t, err)
+
+	patterns := []SearchReplacePattern{
+		SearchReplacePattern{
+			SearchRegex:    makeRegex(t, "one"),
+			ReplacePattern: []byte("ONE"),
+			Occurrences:    -1,
+		},
+		SearchReplacePattern{
+			SearchRegex:    makeRegex(t, "two ONE"),
+			ReplacePattern: []byte("TWO ONE"),
+			Occurrences:    -1,
+			Filter:         &Filter{Exclude: []*regexp.Regexp{makeRegex(t, "^two ONE$")}},
+		},
+	}
+
+	var results []*FileResult
+	opts := FileOptions{DryRun: true, Report: func(r *FileResult) { results = append(results, r) }}
+
+	_, err = FileSearchReplaceWithOptions(inFilePath, inFilePath, patterns, nil, opts)
+	assert.NoError(t, err)
+	_, err = FileSearchReplaceWithOptions(inFilePath, inFilePath, patterns, &Filter{Include: []*regexp.Regexp{makeRegex(t, "three")}}, opts)
+	assert.NoError(t, err)
+	_, err = FileSearchReplaceWithOptions(filepath.Join(dir, "missing"), inFilePath, patterns, nil, opts)
+	assert.Error(t, err)
+
+	assert.Len(t, results, 3)
+	assert.Equal(t, &FileResult{
+		InputPath:  inFilePath,
+		OutputPath: inFilePath,
+		Status:     StatusUpdated,
+		Patterns: []PatternStats{
+			{Search: "one", Found: 3, Replaced: 3},
+			{Search: "two ONE", Found: 2, Filtered: 2},
+		},
+	}, results[0])
+	assert.Equal(t, StatusFiltered, results[1].Status)
+	assert.Equal(t, StatusError, results[2].Status)
+	assert.NotEmpty(t, results[2].Error)
+}
+
+func TestFileSearchReplaceWithOptions_SkipPatternStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	inFilePath := filepath.Join(dir, "f.in")
+	err = ioutil.WriteFile(inFilePath, []byte("one two one"), 0644)
+	assert.NoError(t, err)
+
+	patterns := []SearchReplacePattern{
+		SearchReplacePattern{SearchRegex: makeRegex(t, "one"), ReplacePattern: []byte("ONE"), Occurrences: -1},
+	}
+
+	var result *FileResult
+	opts := FileOptions{DryRun: true, SkipPatternStats: true, Report: func(r *FileResult) { result = r }}
+
+	_, err = FileSearchReplaceWithOptions(inFilePath, inFilePath, patterns, nil, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusUpdated, result.Status)
+	assert.Nil(t, result.Patterns)
+
+	opts.StreamThreshold = 1
+	_, err = FileSearchReplaceWithOptions(inFilePath, inFilePath, patterns, nil, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusUpdated, result.Status)
+	assert.Nil(t, result.Patterns)
+}
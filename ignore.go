@@ -0,0 +1,172 @@
+package gofind
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Names of the ignore files
+const (
+	GitIgnoreFile    = ".gitignore"
+	GoFindIgnoreFile = ".gofindignore"
+)
+
+// ignoreRule is a pattern read from an ignore file
+type ignoreRule struct {
+	glob   *Glob
+	negate bool
+}
+
+// Ignorer decides whether a path is ignored, based on the ignore files found in
+// the directories of the tree, with the syntax of .gitignore files:
+// blank lines and lines starting with '#' are skipped, a pattern starting with '!'
+// re-includes what an earlier pattern ignored, and patterns in a sub directory
+// take priority over the ones in its parents
+//
+// The ignore files are read as the directories are visited. It is not safe for concurrent use
+type Ignorer struct {
+	root      string
+	fileNames []string
+
+	// rules of each directory, by the path relative to root
+	rules map[string][]ignoreRule
+}
+
+// NewIgnorer creates an Ignorer for the tree at root, reading the ignore files named fileNames
+// patterns are additional rules that apply from the root, before the ones from the ignore files
+func NewIgnorer(root string, fileNames []string, patterns ...string) (*Ignorer, error) {
+	ig := &Ignorer{
+		root:      root,
+		fileNames: fileNames,
+		rules:     map[string][]ignoreRule{},
+	}
+
+	rootRules, err := parseIgnoreRules(patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	fileRules, err := ig.readRules(".")
+	if err != nil {
+		return nil, err
+	}
+	ig.rules["."] = append(rootRules, fileRules...)
+
+	return ig, nil
+}
+
+// Ignored reports whether the path, relative to the root, is ignored
+// The parent directories of the path are expected to be visited (and not ignored) first
+func (ig *Ignorer) Ignored(relPath string, isDir bool) (bool, error) {
+	relPath = filepath.ToSlash(relPath)
+	ignored := false
+
+	// Apply the rules from the root down to the parent directory of the path
+	dir := path.Dir(relPath)
+	var dirs []string
+	for d := dir; ; d = path.Dir(d) {
+		dirs = append(dirs, d)
+		if d == "." {
+			break
+		}
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		d := dirs[i]
+		rules, ok := ig.rules[d]
+		if !ok {
+			var err error
+			if rules, err = ig.readRules(d); err != nil {
+				return false, err
+			}
+			ig.rules[d] = rules
+		}
+
+		p := relPath
+		if d != "." {
+			p = strings.TrimPrefix(relPath, d+"/")
+		}
+		for _, rule := range rules {
+			if rule.glob.Match(p, isDir) {
+				ignored = !rule.negate
+			}
+		}
+	}
+
+	return ignored, nil
+}
+
+// readRules reads the ignore files in the directory dir, relative to the root
+func (ig *Ignorer) readRules(dir string) ([]ignoreRule, error) {
+	var rules []ignoreRule
+	for _, fileName := range ig.fileNames {
+		lines, err := readLines(filepath.Join(ig.root, filepath.FromSlash(dir), fileName))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		fileRules, err := parseIgnoreRules(lines)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, fileRules...)
+	}
+
+	return rules, nil
+}
+
+// parseIgnoreRules parses the lines of an ignore file
+func parseIgnoreRules(lines []string) ([]ignoreRule, error) {
+	var rules []ignoreRule
+	for _, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		// Trailing spaces are ignored, unless escaped
+		if !strings.HasSuffix(line, "\\ ") {
+			line = strings.TrimRight(line, " ")
+		}
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var rule ignoreRule
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, "\\#") || strings.HasPrefix(line, "\\!") {
+			line = line[1:]
+		}
+		line = strings.Replace(line, "\\ ", " ", -1)
+
+		glob, err := CompileGlob(line)
+		if err != nil {
+			return nil, err
+		}
+		rule.glob = glob
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// readLines reads a text file as a list of lines
+func readLines(fileName string) ([]string, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	return lines, scanner.Err()
+}
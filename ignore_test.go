@@ -0,0 +1,57 @@
+package gofind
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIgnorer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		".gitignore":     "# Build output\n/build/\n*.log\n!keep.log\nnode_modules/\n",
+		"src/.gitignore": "!debug.log\ngen/\n",
+		".gofindignore":  "*.min.js\n\\#notes\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), os.ModePerm))
+		assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+
+	ig, err := NewIgnorer(dir, []string{GitIgnoreFile, GoFindIgnoreFile}, ".git/")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{".git", true, true},
+		{"build", true, true},
+		{"src/build", true, false},
+		{"app.log", false, true},
+		{"keep.log", false, false},
+		{"src/app.log", false, true},
+		{"src/debug.log", false, false},
+		{"debug.log", false, true},
+		{"src/gen", true, true},
+		{"gen", true, false},
+		{"web/node_modules", true, true},
+		{"web/app.min.js", false, true},
+		{"web/app.js", false, false},
+		{"#notes", false, true},
+	}
+
+	for _, test := range tests {
+		ignored, err := ig.Ignored(test.path, test.isDir)
+		assert.NoError(t, err)
+		assert.Equal(t, test.ignored, ignored, test.path)
+	}
+}
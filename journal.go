@@ -0,0 +1,285 @@
+package gofind
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// journalEntriesFile is the name of the file listing the files written in a run
+	journalEntriesFile = "journal.ndjson"
+	// journalBackupDir is the name of the directory holding the original content of the files
+	journalBackupDir = "files"
+)
+
+// JournalEntry records a file written during a run
+type JournalEntry struct {
+	// Path is the absolute path of the file written
+	Path string `json:"path"`
+	// Backup is the name of the copy of the original content, empty if the file did not exist
+	Backup string `json:"backup,omitempty"`
+	// OriginalHash is the hash of the original content, empty if the file did not exist
+	OriginalHash string `json:"originalHash,omitempty"`
+	// UpdatedHash is the hash of the content written
+	UpdatedHash string `json:"updatedHash"`
+}
+
+// Journal records the files written during a run, so that the run can be undone
+// It is safe for concurrent use
+type Journal struct {
+	// ID identifies the run
+	ID string
+
+	dir     string
+	mu      sync.Mutex
+	entries []JournalEntry
+	// backups is the number of backup names given out, the next one is named after it
+	backups int
+}
+
+// NewJournal creates the journal of a new run in a sub directory of rootDir
+func NewJournal(rootDir string) (*Journal, error) {
+	id := time.Now().Format("20060102-150405.000000")
+	dir := filepath.Join(rootDir, id)
+	if err := os.MkdirAll(filepath.Join(dir, journalBackupDir), os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	return &Journal{ID: id, dir: dir}, nil
+}
+
+// OpenJournal opens the journal of the run id in rootDir
+// If id is empty, the latest run is opened
+func OpenJournal(rootDir, id string) (*Journal, error) {
+	if len(id) == 0 {
+		runs, err := ListJournals(rootDir)
+		if err != nil {
+			return nil, err
+		}
+		if len(runs) == 0 {
+			return nil, fmt.Errorf("No run journal found in %s", rootDir)
+		}
+		id = runs[len(runs)-1]
+	}
+
+	j := &Journal{ID: id, dir: filepath.Join(rootDir, id)}
+	f, err := os.Open(filepath.Join(j.dir, journalEntriesFile))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("Corrupted journal %s. err=%v", id, err)
+		}
+		j.entries = append(j.entries, entry)
+	}
+	j.backups = len(j.entries)
+
+	return j, scanner.Err()
+}
+
+// ListJournals returns the IDs of the runs recorded in rootDir, oldest first
+func ListJournals(rootDir string) ([]string, error) {
+	infos, err := ioutil.ReadDir(rootDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	var ids []string
+	for _, info := range infos {
+		if _, err := os.Stat(filepath.Join(rootDir, info.Name(), journalEntriesFile)); err == nil {
+			ids = append(ids, info.Name())
+		}
+	}
+	sort.Strings(ids)
+
+	return ids, nil
+}
+
+// Entries returns the files recorded in the journal
+func (j *Journal) Entries() []JournalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return append([]JournalEntry(nil), j.entries...)
+}
+
+// Record saves a copy of the file at path, before it is overwritten with the content hashed as updatedHash
+// The copy is made without holding the lock, so that files recorded in parallel are copied in parallel
+func (j *Journal) Record(path, updatedHash string) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	entry := JournalEntry{
+		Path:        absPath,
+		UpdatedHash: updatedHash,
+	}
+
+	if _, err := os.Stat(absPath); err == nil {
+		j.mu.Lock()
+		entry.Backup = filepath.Join(journalBackupDir, strconv.Itoa(j.backups))
+		j.backups++
+		j.mu.Unlock()
+
+		entry.OriginalHash, err = copyFile(absPath, filepath.Join(j.dir, entry.Backup))
+		if err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	line, err := json.Marshal(&entry)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	f, err := os.OpenFile(filepath.Join(j.dir, journalEntriesFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	j.entries = append(j.entries, entry)
+	return nil
+}
+
+// Close removes the journal if no file was recorded
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if len(j.entries) == 0 {
+		return os.RemoveAll(j.dir)
+	}
+
+	return nil
+}
+
+// Undo restores the original content of the files recorded in the journal
+// Files modified after the run are left as they are and reported in the error
+// The journal is removed once all the files are restored
+// Returns the list of files restored
+func (j *Journal) Undo() ([]string, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var restored, conflicts []string
+	// A file may be written more than once, restore in the reverse order
+	for i := len(j.entries) - 1; i >= 0; i-- {
+		entry := &j.entries[i]
+
+		currentHash, err := hashFile(entry.Path)
+		if os.IsNotExist(err) {
+			currentHash, err = "", nil
+		}
+		if err != nil {
+			return restored, err
+		}
+
+		switch currentHash {
+		case entry.OriginalHash: // Already restored
+			continue
+
+		case entry.UpdatedHash:
+			if len(entry.Backup) == 0 {
+				err = os.Remove(entry.Path)
+			} else {
+				_, err = copyFile(filepath.Join(j.dir, entry.Backup), entry.Path)
+			}
+			if err != nil {
+				return restored, err
+			}
+			restored = append(restored, entry.Path)
+
+		default:
+			conflicts = append(conflicts, entry.Path)
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return restored, fmt.Errorf("%d file(s) modified after run %s, not restored: %v", len(conflicts), j.ID, conflicts)
+	}
+
+	return restored, os.RemoveAll(j.dir)
+}
+
+// HashBytes returns the hash of data, as recorded in a journal
+func HashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hashFile returns the hash of the content of a file
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// copyFile copies the content and the permission bits of a file
+// Returns the hash of the content
+func copyFile(src, dst string) (string, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(out, h), in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,136 @@
+package gofind
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJournal_Undo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	journalDir := filepath.Join(dir, "journal")
+	inFilePath := filepath.Join(dir, "f.in")
+	newFilePath := filepath.Join(dir, "out", "f.in")
+	err = ioutil.WriteFile(inFilePath, []byte("one two"), 0644)
+	assert.NoError(t, err)
+
+	patterns := []SearchReplacePattern{
+		SearchReplacePattern{
+			SearchRegex:    makeRegex(t, "one"),
+			ReplacePattern: []byte("ONE"),
+			Occurrences:    -1,
+		},
+	}
+
+	journal, err := NewJournal(journalDir)
+	assert.NoError(t, err)
+
+	// Update the file in place, and write a new output file
+	opts := FileOptions{Journal: journal}
+	_, err = FileSearchReplaceWithOptions(inFilePath, newFilePath, patterns, nil, opts)
+	assert.NoError(t, err)
+	_, err = FileSearchReplaceWithOptions(inFilePath, inFilePath, patterns, nil, opts)
+	assert.NoError(t, err)
+	assert.NoError(t, journal.Close())
+	assert.Len(t, journal.Entries(), 2)
+
+	ids, err := ListJournals(journalDir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{journal.ID}, ids)
+
+	undo, err := OpenJournal(journalDir, "")
+	assert.NoError(t, err)
+	assert.Equal(t, journal.ID, undo.ID)
+
+	restored, err := undo.Undo()
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{inFilePath, newFilePath}, restored)
+
+	content, err := ioutil.ReadFile(inFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("one two"), content)
+
+	_, err = os.Stat(newFilePath)
+	assert.True(t, os.IsNotExist(err))
+
+	ids, err = ListJournals(journalDir)
+	assert.NoError(t, err)
+	assert.Empty(t, ids)
+}
+
+func TestJournal_UndoModified(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filePath := filepath.Join(dir, "f.in")
+	err = ioutil.WriteFile(filePath, []byte("one"), 0644)
+	assert.NoError(t, err)
+
+	journal, err := NewJournal(filepath.Join(dir, "journal"))
+	assert.NoError(t, err)
+	assert.NoError(t, journal.Record(filePath, HashBytes([]byte("ONE"))))
+
+	// Modified after the run
+	err = ioutil.WriteFile(filePath, []byte("One"), 0644)
+	assert.NoError(t, err)
+
+	restored, err := journal.Undo()
+	assert.Error(t, err)
+	assert.Empty(t, restored)
+
+	content, err := ioutil.ReadFile(filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("One"), content)
+}
+
+func TestJournal_RecordParallel(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	journal, err := NewJournal(filepath.Join(dir, "journal"))
+	assert.NoError(t, err)
+
+	var paths []string
+	for i := 0; i < 20; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("f%d.in", i))
+		assert.NoError(t, ioutil.WriteFile(path, []byte(path), 0644))
+		paths = append(paths, path)
+	}
+
+	var wg sync.WaitGroup
+	for _, path := range paths {
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			assert.NoError(t, journal.Record(path, HashBytes([]byte("updated"))))
+			assert.NoError(t, ioutil.WriteFile(path, []byte("updated"), 0644))
+		}(path)
+	}
+	wg.Wait()
+
+	backups := make(map[string]bool)
+	for _, entry := range journal.Entries() {
+		backups[entry.Backup] = true
+	}
+	assert.Len(t, backups, len(paths))
+
+	// Each file gets back its own content
+	restored, err := journal.Undo()
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, paths, restored)
+	for _, path := range paths {
+		content, err := ioutil.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, path, string(content))
+	}
+}
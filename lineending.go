@@ -0,0 +1,93 @@
+package gofind
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// LineEnding is the sequence of characters ending a line
+type LineEnding string
+
+// Line endings
+const (
+	// LineEndingPreserve keeps the line endings of the file as they are
+	LineEndingPreserve LineEnding = ""
+	// LineEndingLF is the Unix line ending
+	LineEndingLF LineEnding = "\n"
+	// LineEndingCRLF is the Windows line ending
+	LineEndingCRLF LineEnding = "\r\n"
+)
+
+// ParseLineEnding returns the line ending with the given name: lf, crlf or preserve (or empty)
+func ParseLineEnding(name string) (LineEnding, error) {
+	switch strings.ToLower(name) {
+	case "", "preserve":
+		return LineEndingPreserve, nil
+	case "lf":
+		return LineEndingLF, nil
+	case "crlf":
+		return LineEndingCRLF, nil
+	}
+
+	return LineEndingPreserve, fmt.Errorf("Unknown line ending '%s', expected lf, crlf or preserve", name)
+}
+
+// DetectLineEnding returns the line ending used by most of the lines of data
+// Data without any line ending is taken as LF
+func DetectLineEnding(data []byte) LineEnding {
+	lines := bytes.Count(data, []byte{'\n'})
+	crlf := bytes.Count(data, []byte{'\r', '\n'})
+	if crlf > lines-crlf {
+		return LineEndingCRLF
+	}
+
+	return LineEndingLF
+}
+
+// ConvertLineEndings returns data with all the line endings (LF or CRLF) converted to le
+// Data is returned as it is for LineEndingPreserve, or if it has no line ending
+// (an empty replacement stays empty, not nil)
+func ConvertLineEndings(data []byte, le LineEnding) []byte {
+	if le == LineEndingPreserve || bytes.IndexByte(data, '\n') < 0 {
+		return data
+	}
+
+	lf := bytes.Replace(data, []byte{'\r', '\n'}, []byte{'\n'}, -1)
+	if le == LineEndingLF {
+		return lf
+	}
+
+	return bytes.Replace(lf, []byte{'\n'}, []byte(le), -1)
+}
+
+// SearchReplaceLineEnding searches inData for the given patterns like SearchReplace,
+// giving the replacement text the line ending of inData, then converts the line
+// endings of the result to le, unless it is LineEndingPreserve
+func SearchReplaceLineEnding(inData []byte, patterns []SearchReplacePattern, le LineEnding) ([]byte, error) {
+	detected := le
+	if detected == LineEndingPreserve {
+		detected = DetectLineEnding(inData)
+	}
+
+	replaced := searchReplace(inData, lineEndingPatterns(patterns, detected), nil, nil)
+	return ConvertLineEndings(replaced, le), nil
+}
+
+// lineEndingPatterns returns a copy of the patterns, with the line endings of
+// the replacement text converted to le
+func lineEndingPatterns(patterns []SearchReplacePattern, le LineEnding) []SearchReplacePattern {
+	converted := append([]SearchReplacePattern(nil), patterns...)
+	for i := range converted {
+		if converted[i].ReplacePattern != nil {
+			converted[i].ReplacePattern = ConvertLineEndings(converted[i].ReplacePattern, le)
+		}
+		if replaceFunc := converted[i].ReplaceFunc; replaceFunc != nil {
+			converted[i].ReplaceFunc = func(m *Submatch) []byte {
+				return ConvertLineEndings(replaceFunc(m), le)
+			}
+		}
+	}
+
+	return converted
+}
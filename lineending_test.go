@@ -0,0 +1,116 @@
+package gofind
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLineEnding(t *testing.T) {
+	for name, expected := range map[string]LineEnding{
+		"":         LineEndingPreserve,
+		"preserve": LineEndingPreserve,
+		"LF":       LineEndingLF,
+		"crlf":     LineEndingCRLF,
+	} {
+		le, err := ParseLineEnding(name)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, le)
+	}
+
+	_, err := ParseLineEnding("cr")
+	assert.Error(t, err)
+}
+
+func TestDetectLineEnding(t *testing.T) {
+	assert.Equal(t, LineEndingLF, DetectLineEnding([]byte("one")))
+	assert.Equal(t, LineEndingLF, DetectLineEnding([]byte("one\ntwo\r\nthree\n")))
+	assert.Equal(t, LineEndingCRLF, DetectLineEnding([]byte("one\r\ntwo\r\nthree\n")))
+}
+
+func TestConvertLineEndings(t *testing.T) {
+	data := []byte("one\r\ntwo\nthree")
+	assert.Equal(t, data, ConvertLineEndings(data, LineEndingPreserve))
+	assert.Equal(t, "one\ntwo\nthree", string(ConvertLineEndings(data, LineEndingLF)))
+	assert.Equal(t, "one\r\ntwo\r\nthree", string(ConvertLineEndings(data, LineEndingCRLF)))
+}
+
+func TestSearchReplaceLineEnding(t *testing.T) {
+	patterns := []SearchReplacePattern{
+		SearchReplacePattern{SearchRegex: makeRegex(t, "b"), ReplacePattern: []byte("X\nY"), Occurrences: -1},
+	}
+
+	replaced, err := SearchReplaceLineEnding([]byte("a b\r\n"), patterns, LineEndingPreserve)
+	assert.NoError(t, err)
+	assert.Equal(t, "a X\r\nY\r\n", string(replaced))
+
+	replaced, err = SearchReplaceLineEnding([]byte("a b\r\n"), patterns, LineEndingLF)
+	assert.NoError(t, err)
+	assert.Equal(t, "a X\nY\n", string(replaced))
+}
+
+func TestFileSearchReplaceWithOptions_LineEnding(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	patterns := []SearchReplacePattern{
+		SearchReplacePattern{
+			SearchRegex:    makeRegex(t, "two"),
+			ReplacePattern: []byte("2\n2"),
+			Occurrences:    -1,
+		},
+	}
+
+	inFilePath := filepath.Join(dir, "f.txt")
+	outFilePath := filepath.Join(dir, "out.txt")
+	err = ioutil.WriteFile(inFilePath, []byte("one\r\ntwo\r\nthree\n"), 0644)
+	assert.NoError(t, err)
+
+	// The replacement follows the dominant line ending, the others are left as they are
+	_, err = FileSearchReplaceWithOptions(inFilePath, outFilePath, patterns, nil, FileOptions{})
+	assert.NoError(t, err)
+	data, err := ioutil.ReadFile(outFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "one\r\n2\r\n2\r\nthree\n", string(data))
+
+	// Normalized files are updated even without any match
+	patterns[0].Occurrences = 0
+	updated, err := FileSearchReplaceWithOptions(inFilePath, outFilePath, patterns, nil, FileOptions{LineEnding: LineEndingLF, StreamThreshold: 1})
+	assert.NoError(t, err)
+	assert.True(t, updated)
+	data, err = ioutil.ReadFile(outFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "one\ntwo\nthree\n", string(data))
+}
+
+func TestFileSearchReplaceWithOptions_EmptyReplacement(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	patterns := []SearchReplacePattern{
+		SearchReplacePattern{
+			SearchRegex:    makeRegex(t, " two"),
+			ReplacePattern: []byte(""),
+			Occurrences:    -1,
+		},
+	}
+	assert.Equal(t, []byte{}, ConvertLineEndings(patterns[0].ReplacePattern, LineEndingCRLF))
+
+	inFilePath := filepath.Join(dir, "f.txt")
+	outFilePath := filepath.Join(dir, "out.txt")
+	err = ioutil.WriteFile(inFilePath, []byte("one two\nthree\n"), 0644)
+	assert.NoError(t, err)
+
+	// An empty replacement deletes the matches
+	updated, err := FileSearchReplaceWithOptions(inFilePath, outFilePath, patterns, nil, FileOptions{})
+	assert.NoError(t, err)
+	assert.True(t, updated)
+	data, err := ioutil.ReadFile(outFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "one\nthree\n", string(data))
+}
@@ -0,0 +1,59 @@
+package gofind
+
+// FileStatus is the outcome of processing a file
+type FileStatus string
+
+// Possible outcomes of processing a file
+const (
+	// StatusUpdated - the output file is written (or would be written, in dry-run mode)
+	StatusUpdated FileStatus = "updated"
+	// StatusNoChange - none of the patterns changed the content
+	StatusNoChange FileStatus = "no-change"
+	// StatusFiltered - the content did not pass the file filter
+	StatusFiltered FileStatus = "filtered"
+	// StatusBinary - the content is binary, and none of the patterns apply on binary files
+	StatusBinary FileStatus = "binary"
+	// StatusError - the file could not be processed
+	StatusError FileStatus = "error"
+)
+
+// PatternStats counts the matches of a search/replace pattern
+type PatternStats struct {
+	Search string `json:"search"`
+	// Found is the number of matches processed
+	Found int `json:"found"`
+	// Replaced is the number of matches replaced
+	Replaced int `json:"replaced"`
+	// Filtered is the number of matches skipped by the pattern filter
+	Filtered int `json:"filtered"`
+}
+
+// FileResult reports the outcome of processing a file
+type FileResult struct {
+	InputPath  string     `json:"input"`
+	OutputPath string     `json:"output"`
+	Status     FileStatus `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	// Patterns has the statistics of each pattern, in the order of the patterns
+	Patterns []PatternStats `json:"patterns,omitempty"`
+}
+
+// newPatternStats returns the statistics to be filled in for the patterns
+func newPatternStats(patterns []SearchReplacePattern) []PatternStats {
+	stats := make([]PatternStats, len(patterns))
+	for i := range patterns {
+		stats[i].Search = patterns[i].SearchRegex.String()
+	}
+
+	return stats
+}
+
+// patternStatsFor returns the statistics to be filled in for the patterns, nil if
+// they are not reported, in which case the matches are not counted
+func patternStatsFor(patterns []SearchReplacePattern, opts FileOptions) []PatternStats {
+	if opts.Report == nil || opts.SkipPatternStats {
+		return nil
+	}
+
+	return newPatternStats(patterns)
+}
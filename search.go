@@ -0,0 +1,96 @@
+package gofind
+
+import (
+	"bytes"
+	"io/ioutil"
+	"sort"
+)
+
+// SearchResult is a match found by Search
+type SearchResult struct {
+	// Pattern is the index of the pattern matched
+	Pattern int
+	// Start and End are the location of the match in the data
+	Start, End int
+	// Line and Column are the position of the start of the match, starting from 1
+	// Column counts bytes
+	Line, Column int
+}
+
+// Search finds the matches of the patterns in data, ordered by their location
+// Unlike SearchReplace, the patterns are searched in the original data, whether
+// they have a ReplacePattern or not. The occurrences and the filter of each pattern apply
+func Search(data []byte, patterns []SearchReplacePattern) []SearchResult {
+	var results []SearchResult
+	for i := range patterns {
+		if patterns[i].Occurrences == 0 {
+			continue
+		}
+
+		for _, loc := range patterns[i].SearchRegex.FindAllIndex(data, patterns[i].Occurrences) {
+			if loc[0] == loc[1] { // Zero length matches are of no use
+				continue
+			}
+
+			if patterns[i].Filter != nil {
+				if bPass, _, _ := patterns[i].Filter.TestFilters(data[loc[0]:loc[1]]); !bPass {
+					continue
+				}
+			}
+
+			results = append(results, SearchResult{Pattern: i, Start: loc[0], End: loc[1]})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Start < results[j].Start
+	})
+
+	// Find the line and column, moving forward from the previous match
+	line, lineStart, pos := 1, 0, 0
+	for i := range results {
+		for pos < results[i].Start {
+			j := bytes.IndexByte(data[pos:results[i].Start], '\n')
+			if j < 0 {
+				pos = results[i].Start
+				break
+			}
+			pos += j + 1
+			line++
+			lineStart = pos
+		}
+		results[i].Line = line
+		results[i].Column = results[i].Start - lineStart + 1
+	}
+
+	return results
+}
+
+// FileSearch reads the file and searches it for the patterns, if the content passes the filter
+// Binary files are handled as in FileSearchReplaceWithOptions, based on opts.Binary
+// The content is decoded to UTF-8 based on opts.Encoding
+// Returns the content of the file along with the matches
+func FileSearch(inFilePath string, patterns []SearchReplacePattern, filter *Filter, opts FileOptions) ([]byte, []SearchResult, error) {
+	fileContent, err := ioutil.ReadFile(inFilePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if fileContent, _, err = DecodeText(fileContent, opts.Encoding); err != nil {
+		return nil, nil, err
+	}
+
+	if !opts.Binary && IsBinary(fileContent) {
+		if patterns = binaryPatterns(patterns); patterns == nil {
+			return fileContent, nil, nil
+		}
+	}
+
+	if filter != nil {
+		if bPass, _, _ := filter.TestFilters(fileContent); !bPass {
+			return fileContent, nil, nil
+		}
+	}
+
+	return fileContent, Search(fileContent, patterns), nil
+}
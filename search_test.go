@@ -0,0 +1,44 @@
+package gofind
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearch(t *testing.T) {
+	testData := []byte("one two\nthree one\n\ntwo one two\n")
+
+	patterns := []SearchReplacePattern{
+		SearchReplacePattern{
+			SearchRegex: makeRegex(t, "two"),
+			Occurrences: -1,
+			Filter:      &Filter{Exclude: []*regexp.Regexp{makeRegex(t, "^two$")}},
+		},
+		SearchReplacePattern{
+			SearchRegex:    makeRegex(t, "one"),
+			ReplacePattern: []byte("ONE"),
+			Occurrences:    2,
+		},
+		SearchReplacePattern{
+			SearchRegex: makeRegex(t, "t(wo)"),
+			Occurrences: -1,
+			Filter:      &Filter{Include: []*regexp.Regexp{makeRegex(t, "^two$")}},
+		},
+		SearchReplacePattern{
+			SearchRegex: makeRegex(t, "x*"),
+			Occurrences: -1,
+		},
+	}
+
+	results := Search(testData, patterns)
+
+	assert.Equal(t, []SearchResult{
+		{Pattern: 1, Start: 0, End: 3, Line: 1, Column: 1},
+		{Pattern: 2, Start: 4, End: 7, Line: 1, Column: 5},
+		{Pattern: 1, Start: 14, End: 17, Line: 2, Column: 7},
+		{Pattern: 2, Start: 19, End: 22, Line: 4, Column: 1},
+		{Pattern: 2, Start: 27, End: 30, Line: 4, Column: 9},
+	}, results)
+}
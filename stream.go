@@ -0,0 +1,222 @@
+package gofind
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"unicode/utf8"
+)
+
+// Defaults for processing the data as a stream
+const (
+	// DefaultMaxMatchSpan is the default length of the longest match expected
+	DefaultMaxMatchSpan = 64 * 1024
+
+	// streamChunkSize is the size of the data read from the input at a time
+	streamChunkSize = 1024 * 1024
+)
+
+// streamStage applies one pattern on the data passing through it
+type streamStage struct {
+	r       *replacer
+	index   int // Index of the pattern
+	pending []byte
+	// start is the length of the context at the start of pending: the last character passed on
+	start int
+}
+
+// feed adds data to the stage and returns the data that is ready to be passed on
+// Data that might be part of a match continuing in the next chunk is kept back, until eof
+func (st *streamStage) feed(data []byte, eof bool, maxMatchSpan int) []byte {
+	st.pending = append(st.pending, data...)
+
+	limit := len(st.pending)
+	if !eof {
+		limit -= maxMatchSpan
+		if limit <= st.start {
+			return nil
+		}
+	}
+
+	out, consumed := st.r.replace(nil, st.pending, st.start, limit)
+
+	// The last character consumed is kept, so that the anchors of the next matches see what precedes them
+	_, st.start = utf8.DecodeLastRune(st.pending[:consumed])
+	st.pending = append(st.pending[:0], st.pending[consumed-st.start:]...)
+
+	return out
+}
+
+// SearchReplaceStream reads the data from r, replaces the given patterns and
+// writes the result to w, without loading all of the data in memory
+//
+// The data is processed in chunks. Matches are expected to be at most maxMatchSpan
+// bytes long (DefaultMaxMatchSpan if not positive); longer matches crossing a
+// chunk boundary may be missed or cut short
+//
+// Returns true if any match was replaced
+func SearchReplaceStream(r io.Reader, w io.Writer, patterns []SearchReplacePattern, maxMatchSpan int) (bool, error) {
+	return searchReplaceStream(r, w, patterns, maxMatchSpan, nil)
+}
+
+// searchReplaceStream processes the stream and, if stats is not nil, counts the matches of each pattern
+func searchReplaceStream(r io.Reader, w io.Writer, patterns []SearchReplacePattern, maxMatchSpan int, stats []PatternStats) (bool, error) {
+	if maxMatchSpan <= 0 {
+		maxMatchSpan = DefaultMaxMatchSpan
+	}
+
+	var stages []*streamStage
+	index := 0
+	for i := range patterns {
+		if !patterns[i].replaces() {
+			continue
+		}
+		stages = append(stages, &streamStage{r: newReplacer(&patterns[i], nil, &index), index: i})
+	}
+
+	chunk := make([]byte, streamChunkSize)
+	for {
+		n, readErr := io.ReadFull(r, chunk)
+		eof := readErr == io.EOF || readErr == io.ErrUnexpectedEOF
+		if readErr != nil && !eof {
+			return false, readErr
+		}
+
+		data := chunk[:n]
+		for _, st := range stages {
+			data = st.feed(data, eof, maxMatchSpan)
+		}
+
+		if len(data) > 0 {
+			if _, err := w.Write(data); err != nil {
+				return false, err
+			}
+		}
+
+		if eof {
+			break
+		}
+	}
+
+	changed := false
+	for _, st := range stages {
+		changed = changed || st.r.changed
+		if stats != nil {
+			st.r.addStats(&stats[st.index])
+		}
+	}
+
+	return changed, nil
+}
+
+// testFiltersFile applies the filter on the content of a file, without loading it in memory
+func testFiltersFile(filter *Filter, path string) (bool, error) {
+	test := func(regEx []*regexp.Regexp) (bool, error) {
+		for _, re := range regEx {
+			f, err := os.Open(path)
+			if err != nil {
+				return false, err
+			}
+			matched := re.MatchReader(bufio.NewReader(f))
+			f.Close()
+			if matched {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	// If an inclusion pattern is specified, one of them has to match
+	if len(filter.Include) > 0 {
+		include, err := test(filter.Include)
+		if err != nil || !include {
+			return false, err
+		}
+	}
+
+	// If one of the exclusion patterns match, fail
+	exclude, err := test(filter.Exclude)
+	if err != nil {
+		return false, err
+	}
+
+	return !exclude, nil
+}
+
+// fileSearchReplaceStream processes a file as a stream
+// The output is written to a temporary file, which replaces the output file if there is any update
+func fileSearchReplaceStream(inFilePath, outFilePath string, patterns []SearchReplacePattern, filter *Filter, opts FileOptions, result *FileResult) (bool, error) {
+	if filter != nil {
+		bPass, err := testFiltersFile(filter, inFilePath)
+		if err != nil {
+			return false, err
+		}
+		if !bPass {
+			result.Status = StatusFiltered
+			return false, nil
+		}
+	}
+
+	result.Patterns = patternStatsFor(patterns, opts)
+
+	in, err := os.Open(inFilePath)
+	if err != nil {
+		return false, err
+	}
+	defer in.Close()
+
+	if opts.DryRun {
+		return searchReplaceStream(in, ioutil.Discard, patterns, opts.MaxMatchSpan, result.Patterns)
+	}
+
+	outputDir := filepath.Dir(outFilePath)
+	if err = os.MkdirAll(outputDir, os.ModePerm); err != nil {
+		return false, err
+	}
+
+	inInfo, err := in.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	// The file a symbolic link points to is written, keeping the link
+	targetPath, err := resolvePath(outFilePath)
+	if err != nil {
+		return false, err
+	}
+	tmp, err := createTempFile(targetPath)
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	h := sha256.New()
+	updated, err := searchReplaceStream(in, io.MultiWriter(w, h), patterns, opts.MaxMatchSpan, result.Patterns)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil || !updated {
+		return false, err
+	}
+
+	in.Close()
+	if opts.Journal != nil {
+		if err = opts.Journal.Record(outFilePath, hex.EncodeToString(h.Sum(nil))); err != nil {
+			return false, err
+		}
+	}
+
+	return true, commitTempFile(tmp.Name(), targetPath, inInfo, opts.PreserveTimestamps)
+}
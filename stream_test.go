@@ -0,0 +1,138 @@
+package gofind
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// makeLargeData returns a few chunks worth of data, so that the matches cross the chunk boundaries
+func makeLargeData() []byte {
+	var buf bytes.Buffer
+	for i := 0; buf.Len() < 3*streamChunkSize; i++ {
+		fmt.Fprintf(&buf, "line %d: one two three\n", i)
+	}
+
+	return buf.Bytes()
+}
+
+func TestSearchReplaceStream(t *testing.T) {
+	testData := makeLargeData()
+
+	patterns := []SearchReplacePattern{
+		SearchReplacePattern{
+			SearchRegex:    makeRegex(t, "one two"),
+			ReplacePattern: []byte("ONE TWO"),
+			Occurrences:    -1,
+		},
+		SearchReplacePattern{
+			SearchRegex:    makeRegex(t, `(?m)^line (\d*5):`),
+			ReplacePattern: []byte("LINE $1:"),
+			Occurrences:    -1,
+			Filter:         &Filter{Exclude: []*regexp.Regexp{makeRegex(t, "5[0-9]5")}},
+		},
+		SearchReplacePattern{
+			SearchRegex:    makeRegex(t, "three"),
+			ReplacePattern: []byte("3"),
+			Occurrences:    100000,
+		},
+	}
+
+	expectedOutput, err := SearchReplace(testData, patterns)
+	assert.NoError(t, err)
+
+	var out bytes.Buffer
+	updated, err := SearchReplaceStream(bytes.NewReader(testData), &out, patterns, 32)
+	assert.NoError(t, err)
+	assert.True(t, updated)
+	assert.True(t, bytes.Equal(expectedOutput, out.Bytes()))
+}
+
+func TestSearchReplaceStream_Anchors(t *testing.T) {
+	// With no line to split at, the first chunk is cut right after "con" of "concat "
+	testData := bytes.Repeat([]byte("concat "), 300000)
+
+	patterns := []SearchReplacePattern{
+		SearchReplacePattern{
+			SearchRegex:    makeRegex(t, `\bcat`),
+			ReplacePattern: []byte("CAT"),
+			Occurrences:    -1,
+		},
+		SearchReplacePattern{
+			SearchRegex:    makeRegex(t, `\Acon`),
+			ReplacePattern: []byte("CON"),
+			Occurrences:    -1,
+		},
+	}
+
+	var out bytes.Buffer
+	updated, err := SearchReplaceStream(bytes.NewReader(testData), &out, patterns, 1002)
+	assert.NoError(t, err)
+	assert.True(t, updated)
+	assert.Equal(t, "CONcat concat ", out.String()[:14])
+	assert.Equal(t, 0, bytes.Count(out.Bytes(), []byte("CAT")))
+	assert.Equal(t, 1, bytes.Count(out.Bytes(), []byte("CON")))
+
+	// The text before the previous match is taken into account too
+	replaced, err := SearchReplace([]byte("aaa"), []SearchReplacePattern{
+		SearchReplacePattern{SearchRegex: makeRegex(t, `\Aa`), ReplacePattern: []byte("A"), Occurrences: 2},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("Aaa"), replaced)
+}
+
+func TestSearchReplaceStream_NoChange(t *testing.T) {
+	patterns := []SearchReplacePattern{
+		SearchReplacePattern{
+			SearchRegex:    makeRegex(t, "Moore"),
+			ReplacePattern: []byte("Mealy"),
+			Occurrences:    -1,
+		},
+	}
+
+	var out bytes.Buffer
+	updated, err := SearchReplaceStream(bytes.NewReader([]byte("one two")), &out, patterns, 0)
+	assert.NoError(t, err)
+	assert.False(t, updated)
+	assert.Equal(t, "one two", out.String())
+}
+
+func TestFileSearchReplaceWithOptions_Stream(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	testData := makeLargeData()
+	inFilePath := filepath.Join(dir, "f.in")
+	err = ioutil.WriteFile(inFilePath, testData, 0644)
+	assert.NoError(t, err)
+
+	patterns := []SearchReplacePattern{
+		SearchReplacePattern{
+			SearchRegex:    makeRegex(t, "two"),
+			ReplacePattern: []byte("TWO"),
+			Occurrences:    -1,
+		},
+	}
+
+	// Content filter is tested on the whole file
+	filter := &Filter{Include: []*regexp.Regexp{makeRegex(t, "line 10000:")}}
+
+	updated, err := FileSearchReplaceWithOptions(inFilePath, inFilePath, patterns, filter, FileOptions{StreamThreshold: 1024})
+	assert.NoError(t, err)
+	assert.True(t, updated)
+
+	content, err := ioutil.ReadFile(inFilePath)
+	assert.NoError(t, err)
+	assert.True(t, bytes.Equal(bytes.Replace(testData, []byte("two"), []byte("TWO"), -1), content))
+
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+}
@@ -0,0 +1,119 @@
+package gofind
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// createTempFile creates a temporary file in the directory of path, to be renamed over path
+// Being in the same directory keeps the rename atomic
+func createTempFile(path string) (*os.File, error) {
+	return ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".gofind-*")
+}
+
+// resolvePath returns the file the symbolic links of path point to, so that the file is
+// updated instead of the link being replaced. Paths that do not exist are returned as they are
+func resolvePath(path string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if os.IsNotExist(err) {
+		return path, nil
+	}
+
+	return resolved, err
+}
+
+// commitTempFile copies the permission bits of srcInfo, and its modification time if
+// preserveTimes is set, on the closed temporary file and renames it over path
+// The owner and the group of the file replaced are kept where possible (e.g. the owner
+// only when running as root). A file with other hard links is overwritten in place
+// instead, as a rename would detach it from them
+func commitTempFile(tmpPath, path string, srcInfo os.FileInfo, preserveTimes bool) error {
+	if err := setFileInfo(tmpPath, srcInfo, preserveTimes); err != nil {
+		return err
+	}
+
+	if info, err := os.Stat(path); err == nil {
+		if uid, gid, ok := fileOwner(info); ok && (uid != os.Getuid() || gid != os.Getgid()) {
+			// Not allowed for most users, the file is then owned by the user
+			os.Chown(tmpPath, uid, gid)
+		}
+		if linkCount(info) > 1 {
+			return overwriteFile(tmpPath, path, srcInfo, preserveTimes)
+		}
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// setFileInfo copies the permission bits of srcInfo, and its modification time if preserveTimes is set, on path
+func setFileInfo(path string, srcInfo os.FileInfo, preserveTimes bool) error {
+	if err := os.Chmod(path, srcInfo.Mode().Perm()); err != nil {
+		return err
+	}
+
+	if preserveTimes {
+		return os.Chtimes(path, time.Now(), srcInfo.ModTime())
+	}
+
+	return nil
+}
+
+// overwriteFile copies the content of the temporary file into path
+func overwriteFile(tmpPath, path string, srcInfo os.FileInfo, preserveTimes bool) error {
+	in, err := os.Open(tmpPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if err == nil {
+		err = out.Sync()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return setFileInfo(path, srcInfo, preserveTimes)
+}
+
+// writeFileAtomic writes data to path through a temporary file, so that path
+// either has its previous content or the complete new content, even after a crash
+// The permission bits (and optionally the modification time) are copied from srcInfo
+// If path is a symbolic link, the file it points to is written
+func writeFileAtomic(path string, data []byte, srcInfo os.FileInfo, preserveTimes bool) error {
+	path, err := resolvePath(path)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := createTempFile(path)
+	if err != nil {
+		return err
+	}
+	// No-op once the file is renamed
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return commitTempFile(tmp.Name(), path, srcInfo, preserveTimes)
+}
@@ -0,0 +1,131 @@
+package gofind
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSearchReplaceWithOptions_PreserveModeAndTime(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	inFilePath := filepath.Join(dir, "f.in")
+	err = ioutil.WriteFile(inFilePath, []byte("one two"), 0640)
+	assert.NoError(t, err)
+	assert.NoError(t, os.Chmod(inFilePath, 0640))
+
+	modTime := time.Date(2019, 11, 6, 10, 0, 0, 0, time.UTC)
+	assert.NoError(t, os.Chtimes(inFilePath, modTime, modTime))
+
+	patterns := []SearchReplacePattern{
+		SearchReplacePattern{
+			SearchRegex:    makeRegex(t, "one"),
+			ReplacePattern: []byte("ONE"),
+			Occurrences:    -1,
+		},
+	}
+
+	outFilePath := filepath.Join(dir, "out", "f.in")
+	updated, err := FileSearchReplaceWithOptions(inFilePath, outFilePath, patterns, nil, FileOptions{})
+	assert.NoError(t, err)
+	assert.True(t, updated)
+
+	info, err := os.Stat(outFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+	assert.False(t, info.ModTime().Equal(modTime))
+
+	// In place, with the modification time preserved
+	updated, err = FileSearchReplaceWithOptions(inFilePath, inFilePath, patterns, nil, FileOptions{PreserveTimestamps: true})
+	assert.NoError(t, err)
+	assert.True(t, updated)
+
+	info, err = os.Stat(inFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+	assert.True(t, info.ModTime().Equal(modTime))
+
+	content, err := ioutil.ReadFile(inFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("ONE two"), content)
+
+	// No temporary file left behind
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+}
+
+func TestFileSearchReplaceWithOptions_Symlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links need privileges on windows")
+	}
+
+	dir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	patterns := []SearchReplacePattern{
+		SearchReplacePattern{
+			SearchRegex:    makeRegex(t, "one"),
+			ReplacePattern: []byte("ONE"),
+			Occurrences:    -1,
+		},
+	}
+
+	// The link is kept and the file it points to is updated, streamed or not
+	for i, opts := range []FileOptions{{}, {StreamThreshold: 1}} {
+		target := filepath.Join(dir, "target"+strconv.Itoa(i))
+		link := filepath.Join(dir, "link"+strconv.Itoa(i))
+		assert.NoError(t, ioutil.WriteFile(target, []byte("one two"), 0644))
+		assert.NoError(t, os.Symlink(filepath.Base(target), link))
+
+		updated, err := FileSearchReplaceWithOptions(link, link, patterns, nil, opts)
+		assert.NoError(t, err)
+		assert.True(t, updated)
+
+		info, err := os.Lstat(link)
+		assert.NoError(t, err)
+		assert.True(t, info.Mode()&os.ModeSymlink != 0, "link replaced")
+		data, err := ioutil.ReadFile(target)
+		assert.NoError(t, err)
+		assert.Equal(t, "ONE two", string(data))
+	}
+}
+
+func TestFileSearchReplaceWithOptions_HardLink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gofind")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	patterns := []SearchReplacePattern{
+		SearchReplacePattern{
+			SearchRegex:    makeRegex(t, "one"),
+			ReplacePattern: []byte("ONE"),
+			Occurrences:    -1,
+		},
+	}
+
+	// The other hard links see the update
+	for i, opts := range []FileOptions{{}, {StreamThreshold: 1}} {
+		path := filepath.Join(dir, "f"+strconv.Itoa(i))
+		other := filepath.Join(dir, "other"+strconv.Itoa(i))
+		assert.NoError(t, ioutil.WriteFile(path, []byte("one two"), 0644))
+		assert.NoError(t, os.Link(path, other))
+
+		updated, err := FileSearchReplaceWithOptions(path, path, patterns, nil, opts)
+		assert.NoError(t, err)
+		assert.True(t, updated)
+
+		data, err := ioutil.ReadFile(other)
+		assert.NoError(t, err)
+		assert.Equal(t, "ONE two", string(data))
+	}
+}
@@ -0,0 +1,29 @@
+//go:build !windows
+// +build !windows
+
+package gofind
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileOwner returns the user and the group owning the file, ok is false if they are unknown
+func fileOwner(info os.FileInfo) (uid, gid int, ok bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+
+	return int(st.Uid), int(st.Gid), true
+}
+
+// linkCount returns the number of hard links to the file, 1 if unknown
+func linkCount(info os.FileInfo) uint64 {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 1
+	}
+
+	return uint64(st.Nlink)
+}